@@ -0,0 +1,44 @@
+package crud
+
+import "context"
+
+// BulkResult is the result of an item of a batch,
+// its updated entity if any, or its error
+type BulkResult struct {
+	Entity interface{}
+	Err    error
+}
+
+// BulkPatch is an item of a batch of merge patches
+type BulkPatch struct {
+	ID    ID
+	Patch PartialUpdateData
+}
+
+// BulkCreator is a manager able to create a batch of entities at once,
+// without it, the REST wrapper calls Create for each of them
+type BulkCreator interface {
+	EntityFactory
+	CreateMany(context.Context, []interface{}) []BulkResult
+}
+
+// BulkPatcher is a manager able to patch a batch of entities at once,
+// without it, the REST wrapper patches them one by one
+type BulkPatcher interface {
+	EntityFactory
+	PatchMany(context.Context, []BulkPatch) []BulkResult
+}
+
+// BulkDeleter is a manager able to delete a batch of entities at once,
+// without it, the REST wrapper calls Delete for each of them
+type BulkDeleter interface {
+	DeleteMany(context.Context, []ID) []BulkResult
+}
+
+// BulkManager is a manager running batches itself
+// The results are in the order of the items
+type BulkManager interface {
+	BulkCreator
+	BulkPatcher
+	BulkDeleter
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/induzo/crud/memstore"
 	"github.com/induzo/crud/mock"
 	"github.com/induzo/crud/rest"
 )
@@ -14,15 +15,11 @@ func main() {
 	r := chi.NewRouter()
 
 	// Subrouters:
-	m := mock.NewMgr()
-	r.Route("/e", func(r chi.Router) {
-		r.Get("/", rest.GETListHandler(m))
-		r.Post("/", rest.POSTHandler(m))
-		r.Get("/{ID}", rest.GETHandler(m))
-		r.Patch("/{ID}", rest.PATCHHandler(m))
-		r.Put("/{ID}", rest.PUTHandler(m))
-		r.Delete("/{ID}", rest.DELETEHandler(m))
-	})
+	m := memstore.New[mock.Entity]()
+	api := rest.NewOpenAPI("example", "1.0.0")
+	rest.Mount(r, m, rest.WithPath("/e"), rest.WithOpenAPI(api))
+	api.Mount(r)
+
 	srv := &http.Server{
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 7200 * time.Second,
@@ -0,0 +1,225 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ParseFields splits the values of the fields list modifier
+// into dot separated JSON field paths: fields=id,owner.name
+func ParseFields(values []string) ([]string, error) {
+	var fields []string
+	for _, v := range values {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" || strings.HasPrefix(f, ".") ||
+				strings.HasSuffix(f, ".") || strings.Contains(f, "..") {
+				return nil, &QueryError{
+					Param:  ParamFields,
+					Reason: fmt.Sprintf("%q is not a field path", f),
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// CheckFields returns a *QueryError if one of the fields is not
+// a JSON field of the type of v, following pointers, slices and maps
+// Anything under a map or an interface is accepted
+func CheckFields(v interface{}, fields []string) error {
+	t := reflect.TypeOf(v)
+	for _, f := range fields {
+		if !hasJSONPath(t, strings.Split(f, ".")) {
+			return &QueryError{
+				Param:  ParamFields,
+				Reason: fmt.Sprintf("unknown field %q", f),
+			}
+		}
+	}
+	return nil
+}
+
+// Project returns the JSON form of v reduced to the given fields,
+// the elements of arrays are reduced one by one
+// v is returned as is without fields
+func Project(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	tree := fieldTree{}
+	for _, f := range fields {
+		tree.add(strings.Split(f, "."))
+	}
+	return tree.project(doc), nil
+}
+
+// fieldTree is a set of field paths, a nil subtree keeps the whole field
+type fieldTree map[string]fieldTree
+
+func (ft fieldTree) add(path []string) {
+	sub, ok := ft[path[0]]
+	if ok && sub == nil {
+		return
+	}
+	if len(path) == 1 {
+		ft[path[0]] = nil
+		return
+	}
+	if !ok {
+		sub = fieldTree{}
+		ft[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+func (ft fieldTree) project(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case []interface{}:
+		for i := range d {
+			d[i] = ft.project(d[i])
+		}
+		return d
+	case map[string]interface{}:
+		p := make(map[string]interface{}, len(ft))
+		for k, sub := range ft {
+			v, ok := d[k]
+			if !ok {
+				continue
+			}
+			if sub != nil {
+				v = sub.project(v)
+			}
+			p[k] = v
+		}
+		return p
+	default:
+		return doc
+	}
+}
+
+// hasJSONPath returns true if path is a JSON field path of t
+func hasJSONPath(t reflect.Type, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+	if t == nil {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return hasJSONPath(t.Elem(), path)
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		f, ok := JSONField(t, path[0])
+		if !ok {
+			return false
+		}
+		return hasJSONPath(f.Type, path[1:])
+	default:
+		return false
+	}
+}
+
+// JSONField returns the field of the struct type t
+// encoded with the JSON name, following embedded structs
+func JSONField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range JSONFields(t) {
+		if f.Name == name {
+			return f.StructField, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// StructJSONField is a struct field along with its JSON encoding
+type StructJSONField struct {
+	reflect.StructField
+	// Name is the JSON name of the field
+	Name string
+	// OmitEmpty is true for fields tagged omitempty
+	OmitEmpty bool
+}
+
+// JSONFields returns the fields of the struct type t as encoding/json
+// sees them: exported, not ignored, and embedded structs flattened
+func JSONFields(t reflect.Type) []StructJSONField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fs []StructJSONField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, ef := range JSONFields(ft) {
+				ef.Index = append([]int{i}, ef.Index...)
+				fs = append(fs, ef)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs = append(fs, StructJSONField{
+			StructField: f,
+			Name:        name,
+			OmitEmpty:   strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return dominantFields(fs)
+}
+
+// dominantFields keeps the shallowest of the fields sharing a JSON name,
+// as encoding/json does
+func dominantFields(fs []StructJSONField) []StructJSONField {
+	depth := make(map[string]int, len(fs))
+	for _, f := range fs {
+		if d, ok := depth[f.Name]; !ok || len(f.Index) < d {
+			depth[f.Name] = len(f.Index)
+		}
+	}
+
+	dfs := fs[:0]
+	for _, f := range fs {
+		if len(f.Index) == depth[f.Name] {
+			dfs = append(dfs, f)
+			depth[f.Name] = -1
+		}
+	}
+	return dfs
+}
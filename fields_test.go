@@ -0,0 +1,135 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type fieldsOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type fieldsBase struct {
+	ID string `json:"id"`
+}
+
+type fieldsEntity struct {
+	fieldsBase
+	Status  int                    `json:"status"`
+	Owner   *fieldsOwner           `json:"owner"`
+	Tags    []fieldsOwner          `json:"tags"`
+	Extra   map[string]interface{} `json:"extra"`
+	Secret  string                 `json:"-"`
+	private string
+}
+
+func TestProject(t *testing.T) {
+	e := &fieldsEntity{
+		fieldsBase: fieldsBase{ID: "a"},
+		Status:     1,
+		Owner:      &fieldsOwner{Name: "pol", Email: "pol@lux.com"},
+		Tags:       []fieldsOwner{{Name: "t1", Email: "e1"}},
+		Extra:      map[string]interface{}{"k": map[string]interface{}{"x": 1}},
+	}
+
+	tests := []struct {
+		name       string
+		v          interface{}
+		fields     []string
+		want       string
+		wantQError bool
+	}{
+		{
+			name:   "top level",
+			v:      e,
+			fields: []string{"id", "status"},
+			want:   `{"id":"a","status":1}`,
+		},
+		{
+			name:   "nested",
+			v:      e,
+			fields: []string{"owner.name", "tags.email"},
+			want:   `{"owner":{"name":"pol"},"tags":[{"email":"e1"}]}`,
+		},
+		{
+			name:   "whole field wins over its subfields",
+			v:      e,
+			fields: []string{"owner.name", "owner"},
+			want:   `{"owner":{"email":"pol@lux.com","name":"pol"}}`,
+		},
+		{
+			name:   "under a map",
+			v:      e,
+			fields: []string{"extra.k.x"},
+			want:   `{"extra":{"k":{"x":1}}}`,
+		},
+		{
+			name:   "list",
+			v:      []*fieldsEntity{e, e},
+			fields: []string{"status"},
+			want:   `[{"status":1},{"status":1}]`,
+		},
+		{
+			name:       "unknown field",
+			v:          e,
+			fields:     []string{"pol"},
+			wantQError: true,
+		},
+		{
+			name:       "ignored field",
+			v:          e,
+			fields:     []string{"Secret"},
+			wantQError: true,
+		},
+		{
+			name:       "unknown nested field",
+			v:          e,
+			fields:     []string{"owner.pol"},
+			wantQError: true,
+		},
+		{
+			name:       "subfield of a scalar",
+			v:          e,
+			fields:     []string{"status.pol"},
+			wantQError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckFields(tt.v, tt.fields)
+			var qe *QueryError
+			if errors.As(err, &qe) != tt.wantQError {
+				t.Errorf("CheckFields() error = %v, wantQError %v",
+					err, tt.wantQError)
+				return
+			}
+			if tt.wantQError {
+				return
+			}
+
+			p, err := Project(tt.v, tt.fields)
+			if err != nil {
+				t.Errorf("Project() error = %v", err)
+				return
+			}
+			if b, _ := json.Marshal(p); string(b) != tt.want {
+				t.Errorf("Project() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fs, err := ParseFields([]string{"id, owner.name", "status"})
+	if err != nil || len(fs) != 3 || fs[1] != "owner.name" {
+		t.Errorf("ParseFields() = %v, %v", fs, err)
+	}
+	for _, bad := range []string{"id,,status", ".id", "owner..name"} {
+		if _, err := ParseFields([]string{bad}); err == nil {
+			t.Errorf("ParseFields(%q) didn't fail", bad)
+		}
+	}
+}
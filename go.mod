@@ -1,17 +1,25 @@
 module github.com/induzo/crud
 
-go 1.15
+go 1.21
 
 require (
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/go-chi/render v1.0.1
+	github.com/google/uuid v1.1.2
+	github.com/induzo/gohttperror v1.0.1
+	github.com/rs/xid v1.2.1
+	github.com/segmentio/ksuid v1.0.3
+)
+
+require (
+	github.com/dlclark/regexp2 v1.2.0 // indirect
 	github.com/golang/mock v1.4.4 // indirect
-	github.com/google/uuid v1.1.2 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
-	github.com/induzo/gohttperror v1.0.1
 	github.com/induzo/gohttpmw v1.0.3 // indirect
+	github.com/ory/ladon v1.2.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/rs/xid v1.2.1
-	github.com/segmentio/ksuid v1.0.3 // indirect
+	github.com/rs/zerolog v1.18.0 // indirect
+	github.com/sirupsen/logrus v1.6.0 // indirect
 	github.com/stretchr/testify v1.6.1 // indirect
+	golang.org/x/sys v0.0.0-20190422165155-953cdadca894 // indirect
 )
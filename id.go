@@ -0,0 +1,128 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/rs/xid"
+	"github.com/segmentio/ksuid"
+)
+
+// ErrInvalidID is returned when an id cannot be parsed
+// or is not of the type expected by the manager
+var ErrInvalidID = errors.New("invalid id")
+
+// ID identifies an entity, its String form is the one used in URLs
+// xid.ID, uuid.UUID, ksuid.KSUID and Int64ID all are IDs
+type ID interface {
+	String() string
+}
+
+// IDParser is implemented by managers whose ids are not xids
+// It parses the String form of an ID back
+type IDParser interface {
+	ParseID(string) (ID, error)
+}
+
+// ParseID parses s with the IDParser of the manager m,
+// falling back on ParseXID if m is not an IDParser
+func ParseID(m interface{}, s string) (ID, error) {
+	if p, ok := m.(IDParser); ok {
+		return p.ParseID(s)
+	}
+	return ParseXID(s)
+}
+
+// Identifiable is an entity able to tell its id
+type Identifiable interface {
+	EntityID() ID
+}
+
+// EntityID returns the String form of the id of the entity e,
+// from its EntityID method, or its "id" JSON field
+// It returns false if e has no id, or a zero one
+func EntityID(e interface{}) (string, bool) {
+	if i, ok := e.(Identifiable); ok {
+		id := i.EntityID()
+		if id == nil || reflect.ValueOf(id).IsZero() {
+			return "", false
+		}
+		return id.String(), true
+	}
+
+	v := reflect.ValueOf(e)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", false
+	}
+	f, ok := JSONField(v.Type(), "id")
+	if !ok {
+		return "", false
+	}
+	fv, err := v.FieldByIndexErr(f.Index)
+	if err != nil || fv.IsZero() {
+		return "", false
+	}
+	if id, ok := fv.Interface().(ID); ok {
+		return id.String(), true
+	}
+	return fmt.Sprint(fv.Interface()), true
+}
+
+// Int64ID is an integer key, as usually generated by databases
+type Int64ID int64
+
+func (id Int64ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// ParseXID parses a non nil github.com/rs/xid id
+func ParseXID(s string) (xid.ID, error) {
+	id, err := xid.FromString(s)
+	if err != nil || id.IsNil() {
+		return xid.NilID(), invalidID(s, err)
+	}
+	return id, nil
+}
+
+// ParseUUID parses a non nil UUID, in any form accepted by google/uuid
+func ParseUUID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil || id == uuid.Nil {
+		return uuid.Nil, invalidID(s, err)
+	}
+	return id, nil
+}
+
+// ParseKSUID parses a non nil ksuid
+func ParseKSUID(s string) (ksuid.KSUID, error) {
+	id, err := ksuid.Parse(s)
+	if err != nil || id == ksuid.Nil {
+		return ksuid.Nil, invalidID(s, err)
+	}
+	return id, nil
+}
+
+// ParseInt64ID parses a strictly positive base 10 integer key
+func ParseInt64ID(s string) (Int64ID, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, invalidID(s, err)
+	}
+	return Int64ID(id), nil
+}
+
+func invalidID(s string, err error) error {
+	if err == nil {
+		return fmt.Errorf("%w %q", ErrInvalidID, s)
+	}
+	return fmt.Errorf("%w %q: %v", ErrInvalidID, s, err)
+}
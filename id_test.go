@@ -0,0 +1,80 @@
+package crud
+
+import (
+	"testing"
+
+	"github.com/rs/xid"
+)
+
+type identified struct {
+	key Int64ID
+}
+
+func (e *identified) EntityID() ID {
+	return e.key
+}
+
+func TestEntityID(t *testing.T) {
+	id := xid.New()
+
+	tests := []struct {
+		name   string
+		e      interface{}
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "Identifiable",
+			e:      &identified{key: 12},
+			want:   "12",
+			wantOK: true,
+		},
+		{
+			name: "zero Identifiable",
+			e:    &identified{},
+		},
+		{
+			name: "ID field",
+			e: &struct {
+				ID xid.ID `json:"id"`
+			}{ID: id},
+			want:   id.String(),
+			wantOK: true,
+		},
+		{
+			name: "embedded ID field",
+			e: &struct {
+				fieldsBase
+				Key int `json:"key"`
+			}{fieldsBase: fieldsBase{ID: "a"}},
+			want:   "a",
+			wantOK: true,
+		},
+		{
+			name: "nil ID field",
+			e: &struct {
+				ID xid.ID `json:"id"`
+			}{},
+		},
+		{
+			name: "no ID field",
+			e: &struct {
+				ID int `json:"key"`
+			}{ID: 1},
+		},
+		{
+			name: "not a struct",
+			e:    map[string]interface{}{"id": "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EntityID(tt.e)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("EntityID() = %q, %v, want %q, %v",
+					got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
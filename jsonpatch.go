@@ -0,0 +1,416 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for malformed patch documents
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchConflict is returned when a patch cannot be applied
+	// to the current state of an entity, a failed test for example
+	ErrPatchConflict = errors.New("patch conflict")
+	// ErrUnprocessableEntity is returned when a patched entity is invalid
+	ErrUnprocessableEntity = errors.New("unprocessable entity")
+)
+
+// JSONPatcher is a manager able to apply RFC 6902 JSON Patches itself,
+// without it, the REST wrapper applies them to the entity returned by Get
+// and saves it with Update
+type JSONPatcher interface {
+	JSONPatch(context.Context, ID, JSONPatch, io.Reader) (interface{}, error)
+}
+
+// PatchOperation is an operation of a JSON Patch
+type PatchOperation struct {
+	Op    string          `json:"op" validate:"oneof=add remove replace move copy test"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document
+type JSONPatch []PatchOperation
+
+// PatchError is the error of the operation Index of a JSON Patch,
+// it wraps ErrInvalidPatch or ErrPatchConflict
+type PatchError struct {
+	Index  int
+	Op     string
+	Path   string
+	Err    error
+	Reason string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%v: operation %d (%s %s): %s",
+		e.Err, e.Index, e.Op, e.Path, e.Reason)
+}
+
+// Unwrap returns ErrInvalidPatch or ErrPatchConflict
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the operations are well formed
+func (p JSONPatch) Validate() error {
+	for i, op := range p {
+		invalid := func(reason string) error {
+			return &PatchError{
+				Index: i, Op: op.Op, Path: op.Path,
+				Err: ErrInvalidPatch, Reason: reason,
+			}
+		}
+
+		if _, err := ParsePointer(op.Path); err != nil {
+			return invalid(err.Error())
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return invalid("missing value")
+			}
+		case "move", "copy":
+			if _, err := ParsePointer(op.From); err != nil {
+				return invalid("from: " + err.Error())
+			}
+			if op.Op == "move" && op.Path != op.From &&
+				strings.HasPrefix(op.Path+"/", op.From+"/") {
+				return invalid("cannot move a value into itself")
+			}
+		case "remove":
+		default:
+			return invalid("unknown operation")
+		}
+	}
+	return nil
+}
+
+// Apply applies the patch to the JSON document doc, atomically
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	d, err := decodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		if d, err = op.apply(d); err != nil {
+			return nil, &PatchError{
+				Index: i, Op: op.Op, Path: op.Path,
+				Err: ErrPatchConflict, Reason: err.Error(),
+			}
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+// ApplyTo applies the patch to the JSON form of e, and decodes the result
+// into target, usually a new empty entity
+func (p JSONPatch) ApplyTo(e interface{}, target interface{}) error {
+	doc, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	patched, err := p.Apply(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, target); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnprocessableEntity, err)
+	}
+	return nil
+}
+
+func (op PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, _ := ParsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		v, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "remove":
+		d, _, err := remove(doc, path)
+		return d, err
+	case "replace":
+		v, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		d, _, err := remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(d, path, v)
+	case "move":
+		from, _ := ParsePointer(op.From)
+		d, v, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(d, path, v)
+	case "copy":
+		from, _ := ParsePointer(op.From)
+		v, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(v))
+	default: // test
+		want, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		got, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(got, want) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Pointer is a parsed RFC 6901 JSON Pointer, the empty pointer
+// being the whole document
+type Pointer []string
+
+// ParsePointer parses an RFC 6901 JSON Pointer: /owner/name
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%q is not a JSON pointer", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = pointerUnescaper.Replace(t)
+	}
+	return Pointer(tokens), nil
+}
+
+// String returns the RFC 6901 form of the pointer
+func (p Pointer) String() string {
+	var sb strings.Builder
+	for _, t := range p {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(t))
+	}
+	return sb.String()
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func arrayIndex(a []interface{}, token string, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return len(a), nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	max := len(a) - 1
+	if allowEnd {
+		max = len(a)
+	}
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("array index %q out of bounds", token)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, path Pointer) (interface{}, error) {
+	for _, t := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(d, t, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("%q not found", t)
+		}
+	}
+	return doc, nil
+}
+
+// add sets v at path, inserting it if path is an array index
+func add(doc interface{}, path Pointer, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = v
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(p, last, true)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, nil)
+		copy(p[i+1:], p[i:])
+		p[i] = v
+		return replaceAt(doc, path[:len(path)-1], p)
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar", last)
+	}
+}
+
+// remove removes the value at path, and returns it
+func remove(doc interface{}, path Pointer) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%q not found", last)
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []interface{}:
+		i, err := arrayIndex(p, last, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		p = append(p[:i:i], p[i+1:]...)
+		d, err := replaceAt(doc, path[:len(path)-1], p)
+		return d, v, err
+	default:
+		return nil, nil, fmt.Errorf("%q not found", last)
+	}
+}
+
+// replaceAt replaces the value at path, which exists, by v
+func replaceAt(
+	doc interface{},
+	path Pointer,
+	v interface{},
+) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = v
+	case []interface{}:
+		i, _ := arrayIndex(p, last, false)
+		p[i] = v
+	}
+	return doc, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch d := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(d))
+		for k, e := range d {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(d))
+		for i, e := range d {
+			c[i] = deepCopy(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual compares decoded JSON values, numbers by their value
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, okx := new(big.Float).SetString(string(x))
+		fy, oky := new(big.Float).SetString(string(y))
+		return okx && oky && fx.Cmp(fy) == 0
+	default:
+		return a == b
+	}
+}
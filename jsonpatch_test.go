@@ -0,0 +1,150 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPatchApply(t *testing.T) {
+	doc := `{"a":1,"b":{"c":[1,2]},"d~/e":"x"}`
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "add a key",
+			patch: `[{"op":"add","path":"/z","value":{"y":null}}]`,
+			want:  `{"a":1,"b":{"c":[1,2]},"d~/e":"x","z":{"y":null}}`,
+		},
+		{
+			name:  "add inside an array",
+			patch: `[{"op":"add","path":"/b/c/1","value":3}]`,
+			want:  `{"a":1,"b":{"c":[1,3,2]},"d~/e":"x"}`,
+		},
+		{
+			name:  "append to an array",
+			patch: `[{"op":"add","path":"/b/c/-","value":3}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"d~/e":"x"}`,
+		},
+		{
+			name:  "remove an escaped key",
+			patch: `[{"op":"remove","path":"/d~0~1e"}]`,
+			want:  `{"a":1,"b":{"c":[1,2]}}`,
+		},
+		{
+			name: "replace and test",
+			patch: `[{"op":"test","path":"/a","value":1.0},` +
+				`{"op":"replace","path":"/a","value":2}]`,
+			want: `{"a":2,"b":{"c":[1,2]},"d~/e":"x"}`,
+		},
+		{
+			name:  "move",
+			patch: `[{"op":"move","from":"/b/c","path":"/c"}]`,
+			want:  `{"a":1,"b":{},"c":[1,2],"d~/e":"x"}`,
+		},
+		{
+			name:  "copy",
+			patch: `[{"op":"copy","from":"/b/c/0","path":"/b/c/-"}]`,
+			want:  `{"a":1,"b":{"c":[1,2,1]},"d~/e":"x"}`,
+		},
+		{
+			name:    "failed test",
+			patch:   `[{"op":"test","path":"/a","value":"1"}]`,
+			wantErr: ErrPatchConflict,
+		},
+		{
+			name:    "remove a missing key",
+			patch:   `[{"op":"remove","path":"/z"}]`,
+			wantErr: ErrPatchConflict,
+		},
+		{
+			name:    "array index out of range",
+			patch:   `[{"op":"replace","path":"/b/c/2","value":1}]`,
+			wantErr: ErrPatchConflict,
+		},
+		{
+			name:    "unknown operation",
+			patch:   `[{"op":"merge","path":"/a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "missing value",
+			patch:   `[{"op":"add","path":"/a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "invalid pointer",
+			patch:   `[{"op":"remove","path":"a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "move into itself",
+			patch:   `[{"op":"move","from":"/b","path":"/b/c"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p JSONPatch
+			if err := json.Unmarshal([]byte(tt.patch), &p); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := p.Apply([]byte(doc))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var pe *PatchError
+				if !errors.As(err, &pe) {
+					t.Errorf("Apply() error %T is not a *PatchError", err)
+				}
+				return
+			}
+			var gotV, wantV interface{}
+			_ = json.Unmarshal(got, &gotV)
+			_ = json.Unmarshal([]byte(tt.want), &wantV)
+			if !reflect.DeepEqual(gotV, wantV) {
+				t.Errorf("Apply() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Pointer
+		wantErr bool
+	}{
+		{s: "", want: Pointer{}},
+		{s: "/", want: Pointer{""}},
+		{s: "/a~1b/~01", want: Pointer{"a/b", "~1"}},
+		{s: "a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParsePointer(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePointer() error = %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.want) ||
+				(len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("ParsePointer() = %#v, want %#v", got, tt.want)
+			}
+			if got.String() != tt.s {
+				t.Errorf("String() = %q, want %q", got.String(), tt.s)
+			}
+		})
+	}
+}
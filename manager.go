@@ -0,0 +1,131 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/induzo/gohttperror"
+)
+
+// ErrEntityType is returned by an adapted Manager when it is handed
+// an entity that is not of its type
+var ErrEntityType = errors.New("unexpected entity type")
+
+// Manager is the type safe counterpart of MgrI,
+// K is the id type and T the entity type, usually a pointer to a struct
+type Manager[K ID, T any] interface {
+	ParseID(string) (K, error)
+	NewEmptyEntity() T
+	Create(context.Context, T, io.Reader) (T, error)
+	Delete(context.Context, K) error
+	Get(context.Context, K) (T, error)
+	GetList(context.Context, ListModifiers) ([]T, error)
+	Update(context.Context, K, T, io.Reader) (T, error)
+	PartialUpdate(context.Context, K, PartialUpdateData, io.Reader) error
+	MapErrorToHTTPError(error) *gohttperror.ErrResponse
+}
+
+// Adapt wraps a typed Manager so it can be used wherever a MgrI is expected
+func Adapt[K ID, T any](m Manager[K, T]) MgrI {
+	return &adapter[K, T]{m: m}
+}
+
+type adapter[K ID, T any] struct {
+	m Manager[K, T]
+}
+
+func (a *adapter[K, T]) cast(e interface{}) (T, error) {
+	ec, ok := e.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: got %T, want %T", ErrEntityType, e, zero)
+	}
+	return ec, nil
+}
+
+func (a *adapter[K, T]) key(id ID) (K, error) {
+	k, ok := id.(K)
+	if !ok {
+		return k, fmt.Errorf("%w: got %T, want %T", ErrInvalidID, id, k)
+	}
+	return k, nil
+}
+
+func (a *adapter[K, T]) ParseID(s string) (ID, error) {
+	return a.m.ParseID(s)
+}
+
+func (a *adapter[K, T]) NewEmptyEntity() interface{} {
+	return a.m.NewEmptyEntity()
+}
+
+func (a *adapter[K, T]) Create(
+	ctx context.Context,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	ec, err := a.cast(e)
+	if err != nil {
+		return nil, err
+	}
+	return a.m.Create(ctx, ec, pl)
+}
+
+func (a *adapter[K, T]) Delete(ctx context.Context, id ID) error {
+	k, err := a.key(id)
+	if err != nil {
+		return err
+	}
+	return a.m.Delete(ctx, k)
+}
+
+func (a *adapter[K, T]) Get(ctx context.Context, id ID) (interface{}, error) {
+	k, err := a.key(id)
+	if err != nil {
+		return nil, err
+	}
+	return a.m.Get(ctx, k)
+}
+
+func (a *adapter[K, T]) GetList(
+	ctx context.Context,
+	lm ListModifiers,
+) (interface{}, error) {
+	return a.m.GetList(ctx, lm)
+}
+
+func (a *adapter[K, T]) Update(
+	ctx context.Context,
+	id ID,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	k, err := a.key(id)
+	if err != nil {
+		return nil, err
+	}
+	ec, err := a.cast(e)
+	if err != nil {
+		return nil, err
+	}
+	return a.m.Update(ctx, k, ec, pl)
+}
+
+func (a *adapter[K, T]) PartialUpdate(
+	ctx context.Context,
+	id ID,
+	pud PartialUpdateData,
+	pl io.Reader,
+) error {
+	k, err := a.key(id)
+	if err != nil {
+		return err
+	}
+	return a.m.PartialUpdate(ctx, k, pud, pl)
+}
+
+func (a *adapter[K, T]) MapErrorToHTTPError(e error) *gohttperror.ErrResponse {
+	return a.m.MapErrorToHTTPError(e)
+}
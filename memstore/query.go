@@ -0,0 +1,299 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+// defaultSpec allows filtering and sorting on all the JSON fields of t
+func defaultSpec(t reflect.Type) *crud.QuerySpec {
+	spec := &crud.QuerySpec{Filterable: map[string][]crud.Operator{}}
+	for _, f := range crud.JSONFields(t) {
+		spec.Filterable[f.Name] = nil
+		spec.Sortable = append(spec.Sortable, f.Name)
+	}
+	return spec
+}
+
+// GetList returns the entities matching the filters of lm, in the order
+// of creation unless sorted otherwise
+// With a limit, the list is a *crud.Page whose cursors are offsets,
+// and a bare []*E otherwise
+func (s *Store[E]) GetList(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (interface{}, error) {
+	q, err := crud.ParseQuery(lm, *s.o.spec)
+	if err != nil {
+		return nil, err
+	}
+	offset := q.Offset
+	if q.Cursor != "" {
+		offset, err = strconv.Atoi(q.Cursor)
+		if err != nil || offset < 0 {
+			return nil, &crud.QueryError{
+				Param:  crud.ParamPageToken,
+				Reason: "unknown cursor",
+			}
+		}
+	}
+
+	s.mu.RLock()
+	recs, err := s.match(q.Filters)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	sortRecords(recs, q.Sort)
+
+	total := len(recs)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if q.Limit > 0 && offset+q.Limit < total {
+		end = offset + q.Limit
+	}
+
+	items := make([]*E, 0, end-offset)
+	for _, rec := range recs[offset:end] {
+		e, err := s.decode(rec)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	if q.Limit == 0 {
+		return items, nil
+	}
+
+	p := &crud.Page{Items: items}
+	if end < total {
+		p.NextCursor = strconv.Itoa(end)
+	}
+	if offset > 0 {
+		p.PrevCursor = strconv.Itoa(max(offset-q.Limit, 0))
+	}
+	return p, nil
+}
+
+// Count returns the number of entities matching the filters of lm
+func (s *Store[E]) Count(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (int64, error) {
+	q, err := crud.ParseQuery(lm, *s.o.spec)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recs, err := s.match(q.Filters)
+	return int64(len(recs)), err
+}
+
+// match returns the records matching all the filters, under the read lock
+func (s *Store[E]) match(filters []crud.Filter) ([]*record, error) {
+	recs := make([]*record, 0, len(s.records))
+	for _, rec := range s.records {
+		ok := true
+		for _, f := range filters {
+			var err error
+			if ok, err = matches(rec.doc, f); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+		if ok {
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
+// sortRecords sorts recs on sorts, then in the order of creation
+func sortRecords(recs []*record, sorts []crud.Sort) {
+	sort.Slice(recs, func(i, j int) bool {
+		for _, srt := range sorts {
+			c := compareValues(
+				lookup(recs[i].doc, srt.Field),
+				lookup(recs[j].doc, srt.Field),
+			)
+			if c != 0 {
+				return (c < 0) != srt.Desc
+			}
+		}
+		return recs[i].seq < recs[j].seq
+	})
+}
+
+// lookup returns the value of the dot separated field path in doc
+func lookup(doc map[string]interface{}, field string) interface{} {
+	var v interface{} = doc
+	for _, name := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// matches returns true if the value of the field of doc passes f
+func matches(doc map[string]interface{}, f crud.Filter) (bool, error) {
+	v := lookup(doc, f.Field)
+
+	if f.Operator == crud.Contains {
+		switch x := v.(type) {
+		case string:
+			return strings.Contains(x, f.Value()), nil
+		case []interface{}:
+			for _, item := range x {
+				if c, err := compare(f, item, f.Value()); err != nil {
+					return false, err
+				} else if c == 0 {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+
+	for _, value := range f.Values {
+		c, err := compare(f, v, value)
+		if err != nil {
+			return false, err
+		}
+		var ok bool
+		switch f.Operator {
+		case crud.Eq, crud.In:
+			ok = c == 0
+		case crud.Ne:
+			ok = c != 0
+		case crud.Gt:
+			ok = c > 0
+		case crud.Gte:
+			ok = c >= 0
+		case crud.Lt:
+			ok = c < 0
+		case crud.Lte:
+			ok = c <= 0
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compare compares the JSON value v to the filter value s,
+// parsed according to the type of v
+func compare(f crud.Filter, v interface{}, s string) (int, error) {
+	invalid := func(reason string) error {
+		return &crud.QueryError{Param: f.Field, Reason: reason}
+	}
+	switch x := v.(type) {
+	case nil:
+		if s == "null" {
+			return 0, nil
+		}
+		return -1, nil
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return 0, invalid(fmt.Sprintf("%q is not a boolean", s))
+		}
+		return compareValues(x, b), nil
+	case json.Number:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return 0, invalid(fmt.Sprintf("%q is not a number", s))
+		}
+		return compareValues(x, json.Number(s)), nil
+	case string:
+		return compareValues(x, s), nil
+	default:
+		b, _ := json.Marshal(x)
+		return strings.Compare(string(b), s), nil
+	}
+}
+
+// rank orders the JSON values of different types
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number:
+		return 2
+	case string:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// compareValues compares two JSON values, null first, then booleans,
+// numbers, strings, RFC 3339 date-times by their time, and the rest
+func compareValues(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case json.Number:
+		return compareNumbers(x, b.(json.Number))
+	case string:
+		y := b.(string)
+		tx, errX := time.Parse(time.RFC3339Nano, x)
+		ty, errY := time.Parse(time.RFC3339Nano, y)
+		if errX == nil && errY == nil {
+			return tx.Compare(ty)
+		}
+		return strings.Compare(x, y)
+	}
+	return 0
+}
+
+// compareNumbers compares integers exactly, and other numbers as floats
+func compareNumbers(a, b json.Number) int {
+	ia, errA := a.Int64()
+	ib, errB := b.Int64()
+	if errA == nil && errB == nil {
+		switch {
+		case ia < ib:
+			return -1
+		case ia > ib:
+			return 1
+		}
+		return 0
+	}
+	fa, _ := a.Float64()
+	fb, _ := b.Float64()
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,229 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/induzo/crud"
+)
+
+type product struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Price     float64  `json:"price"`
+	Available bool     `json:"available"`
+	Tags      []string `json:"tags"`
+	Owner     *owner   `json:"owner"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type owner struct {
+	Name string `json:"name"`
+}
+
+func seed(t *testing.T, opts ...Option) *Store[product] {
+	t.Helper()
+	s := New[product](append([]Option{WithSequentialIDs()}, opts...)...)
+	for _, p := range []product{
+		{Name: "pen", Price: 1.5, Available: true, Tags: []string{"office"},
+			CreatedAt: "2021-01-02T00:00:00Z"},
+		{Name: "ink", Price: 12, Owner: &owner{Name: "ann"},
+			CreatedAt: "2021-01-01T10:00:00+02:00"},
+		{Name: "pad", Price: 3, Available: true,
+			Tags: []string{"office", "paper"}, CreatedAt: "2021-01-03T00:00:00Z"},
+		{Name: "box", Price: 3, Owner: &owner{Name: "bob"},
+			CreatedAt: "2020-12-31T00:00:00Z"},
+	} {
+		p := p
+		if _, err := s.Create(context.Background(), &p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func names(t *testing.T, list interface{}) []string {
+	t.Helper()
+	var ps []*product
+	switch l := list.(type) {
+	case []*product:
+		ps = l
+	case *crud.Page:
+		ps = l.Items.([]*product)
+	default:
+		t.Fatalf("GetList() returned a %T", list)
+	}
+	ns := make([]string, len(ps))
+	for i, p := range ps {
+		ns[i] = p.Name
+	}
+	return ns
+}
+
+func TestGetList(t *testing.T) {
+	s := seed(t)
+
+	tests := []struct {
+		name     string
+		lm       crud.ListModifiers
+		want     []string
+		wantNext string
+		wantPrev string
+		wantErr  bool
+	}{
+		{
+			name: "creation order",
+			want: []string{"pen", "ink", "pad", "box"},
+		},
+		{
+			name: "equal",
+			lm:   crud.ListModifiers{"name": {"ink"}},
+			want: []string{"ink"},
+		},
+		{
+			name: "numbers and booleans",
+			lm: crud.ListModifiers{
+				"price[gte]": {"3"}, "available": {"false"},
+			},
+			want: []string{"ink", "box"},
+		},
+		{
+			name: "in",
+			lm:   crud.ListModifiers{"name[in]": {"box,pen,cup"}},
+			want: []string{"pen", "box"},
+		},
+		{
+			name: "contains",
+			lm:   crud.ListModifiers{"tags[contains]": {"paper"}},
+			want: []string{"pad"},
+		},
+		{
+			name: "substring",
+			lm:   crud.ListModifiers{"name[contains]": {"p"}},
+			want: []string{"pen", "pad"},
+		},
+		{
+			name: "not null",
+			lm:   crud.ListModifiers{"owner[ne]": {"null"}},
+			want: []string{"ink", "box"},
+		},
+		{
+			name: "date-times",
+			lm: crud.ListModifiers{
+				"created_at[lt]": {"2021-01-01T12:00:00Z"},
+			},
+			want: []string{"ink", "box"},
+		},
+		{
+			name: "sorted",
+			lm:   crud.ListModifiers{"orderby": {"price DESC,name"}},
+			want: []string{"ink", "box", "pad", "pen"},
+		},
+		{
+			name: "sorted on date-times",
+			lm:   crud.ListModifiers{"orderby": {"created_at"}},
+			want: []string{"box", "ink", "pen", "pad"},
+		},
+		{
+			name:     "first page",
+			lm:       crud.ListModifiers{"limit": {"2"}},
+			want:     []string{"pen", "ink"},
+			wantNext: "2",
+		},
+		{
+			name:     "page by cursor",
+			lm:       crud.ListModifiers{"limit": {"2"}, "page_token": {"2"}},
+			want:     []string{"pad", "box"},
+			wantPrev: "0",
+		},
+		{
+			name:     "offset",
+			lm:       crud.ListModifiers{"limit": {"2"}, "offset": {"1"}},
+			want:     []string{"ink", "pad"},
+			wantNext: "3",
+			wantPrev: "0",
+		},
+		{
+			name: "past the end",
+			lm:   crud.ListModifiers{"offset": {"9"}},
+			want: []string{},
+		},
+		{
+			name:    "not a number",
+			lm:      crud.ListModifiers{"price": {"cheap"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			lm:      crud.ListModifiers{"color": {"red"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid cursor",
+			lm:      crud.ListModifiers{"limit": {"2"}, "page_token": {"x"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.GetList(context.Background(), tt.lm)
+			if tt.wantErr {
+				var qe *crud.QueryError
+				if !errors.As(err, &qe) {
+					t.Errorf("GetList() error = %v, want a QueryError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetList() error = %v", err)
+			}
+			got := names(t, list)
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetList() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("GetList() = %v, want %v", got, tt.want)
+				}
+			}
+			if p, ok := list.(*crud.Page); ok &&
+				(p.NextCursor != tt.wantNext || p.PrevCursor != tt.wantPrev) {
+				t.Errorf("GetList() cursors %q and %q, want %q and %q",
+					p.NextCursor, p.PrevCursor, tt.wantNext, tt.wantPrev)
+			}
+
+			if len(tt.lm[crud.ParamLimit])+len(tt.lm[crud.ParamOffset]) > 0 {
+				return
+			}
+			n, err := s.Count(context.Background(), tt.lm)
+			if err != nil || int(n) != len(got) {
+				t.Errorf("Count() = %d, %v, want %d", n, err, len(got))
+			}
+		})
+	}
+}
+
+func TestGetListQuerySpec(t *testing.T) {
+	s := seed(t, WithQuerySpec(crud.QuerySpec{
+		Filterable: map[string][]crud.Operator{"name": {crud.Eq}},
+		Sortable:   []string{"price"},
+		MaxLimit:   10,
+	}))
+
+	for _, lm := range []crud.ListModifiers{
+		{"price": {"3"}},
+		{"name[ne]": {"pen"}},
+		{"orderby": {"name"}},
+		{"limit": {"11"}},
+	} {
+		if _, err := s.GetList(context.Background(), lm); err == nil {
+			t.Errorf("GetList(%v) didn't fail", lm)
+		}
+	}
+	if _, err := s.GetList(context.Background(),
+		crud.ListModifiers{"name": {"pen"}, "orderby": {"price"}}); err != nil {
+		t.Errorf("GetList() error = %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package memstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+// snapshot is the JSON document the entities are saved to,
+// in the order of their creation
+type snapshot struct {
+	Entities []snapshotEntity `json:"entities"`
+}
+
+type snapshotEntity struct {
+	ModifiedAt time.Time       `json:"modified_at"`
+	Entity     json.RawMessage `json:"entity"`
+}
+
+// WriteTo writes the snapshot of the store to w
+func (s *Store[E]) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	b, err := s.marshalSnapshot()
+	s.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// ReadFrom replaces the entities of the store by the ones of the snapshot
+// read from r, persisted stores save it right away
+func (s *Store[E]) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(b)), err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(b)), s.restore(b)
+}
+
+// Save writes the snapshot of the store to the file path,
+// atomically replacing it
+func (s *Store[E]) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.save(path)
+}
+
+// Load replaces the entities of the store by the ones of the snapshot
+// file path, the error wraps os.ErrNotExist if there is none
+// Persisted stores save it right away
+func (s *Store[E]) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.restore(b); err != nil {
+		return fmt.Errorf("memstore: loading %s: %w", path, err)
+	}
+	return nil
+}
+
+// restore replaces the records by the ones of the snapshot b and
+// persists them, leaving the store as it was if it fails, under the lock
+func (s *Store[E]) restore(b []byte) error {
+	records, seq, lastID := s.records, s.seq, s.lastID
+	if err := s.unmarshalSnapshot(b); err != nil {
+		return err
+	}
+	if err := s.persist(); err != nil {
+		s.records, s.seq, s.lastID = records, seq, lastID
+		return err
+	}
+	return nil
+}
+
+// persist saves the snapshot of persisted stores, under the lock
+func (s *Store[E]) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	return s.save(s.path)
+}
+
+// save writes the snapshot to a temporary file renamed to path,
+// under the lock
+func (s *Store[E]) save(path string) error {
+	b, err := s.marshalSnapshot()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *Store[E]) marshalSnapshot() ([]byte, error) {
+	recs := make([]*record, 0, len(s.records))
+	for _, rec := range s.records {
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].seq < recs[j].seq })
+
+	snap := snapshot{Entities: make([]snapshotEntity, len(recs))}
+	for i, rec := range recs {
+		snap.Entities[i] = snapshotEntity{
+			ModifiedAt: rec.modified,
+			Entity:     rec.data,
+		}
+	}
+	return json.MarshalIndent(snap, "", "\t")
+}
+
+// unmarshalSnapshot replaces the records by the ones of the snapshot b,
+// under the write lock
+func (s *Store[E]) unmarshalSnapshot(b []byte) error {
+	var snap snapshot
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&snap); err != nil {
+		return err
+	}
+
+	records := make(map[string]*record, len(snap.Entities))
+	var lastID int64
+	for i, se := range snap.Entities {
+		e := new(E)
+		if err := json.Unmarshal(se.Entity, e); err != nil {
+			return err
+		}
+		key, ok := crud.EntityID(e)
+		if !ok {
+			return fmt.Errorf("entity %d has no id", i)
+		}
+		id, err := s.o.parseID(key)
+		if err != nil {
+			return err
+		}
+		if _, ok := records[id.String()]; ok {
+			return fmt.Errorf("%w: %s", ErrConflict, id)
+		}
+		if n, ok := id.(crud.Int64ID); ok && int64(n) > lastID {
+			lastID = int64(n)
+		}
+
+		rec, err := s.encode(id, e)
+		if err != nil {
+			return err
+		}
+		rec.modified = se.ModifiedAt
+		rec.seq = uint64(i + 1)
+		records[id.String()] = rec
+	}
+
+	s.records = records
+	s.seq = uint64(len(snap.Entities))
+	s.lastID = lastID
+	return nil
+}
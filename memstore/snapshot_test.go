@@ -0,0 +1,110 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/induzo/crud"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := seed(t)
+	if err := s.Delete(ctx, crud.Int64ID(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	r := New[product](WithSequentialIDs())
+	if _, err := r.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	list, _ := r.GetList(ctx, nil)
+	if got := names(t, list); len(got) != 3 || got[2] != "pad" {
+		t.Errorf("ReadFrom() restored %v", got)
+	}
+	e, _ := r.Get(ctx, crud.Int64ID(1))
+	if !r.LastModified(e).Equal(s.LastModified(e)) {
+		t.Errorf("ReadFrom() didn't restore the modification times")
+	}
+
+	// ids go on after the restored ones
+	e, err := r.Create(ctx, &product{Name: "cup"}, nil)
+	if err != nil || e.(*product).ID != 4 {
+		t.Errorf("Create() after ReadFrom() = %+v, %v", e, err)
+	}
+
+	if _, err := r.ReadFrom(bytes.NewBufferString(`{"entities": [
+		{"entity": {"id": 1}}, {"entity": {"id": 1}}
+	]}`)); err == nil {
+		t.Errorf("ReadFrom() of duplicate ids didn't fail")
+	}
+	if r.Len() != 4 {
+		t.Errorf("a failed ReadFrom() changed the store")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	s, err := Open[product](path, WithSequentialIDs())
+	if err != nil {
+		t.Fatalf("Open() of a new file error = %v", err)
+	}
+	if _, err := s.Create(ctx, &product{Name: "pen"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PartialUpdate(ctx, crud.Int64ID(1),
+		crud.PartialUpdateData{"price": 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open[product](path, WithSequentialIDs())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	e, err := reopened.Get(ctx, crud.Int64ID(1))
+	if err != nil || e.(*product).Price != 2 {
+		t.Errorf("Open() restored %+v, %v", e, err)
+	}
+
+	// a failed save leaves the store as it was
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, &product{Name: "ink"}, nil); err == nil {
+		t.Errorf("Create() didn't fail to save")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d after a failed save", s.Len())
+	}
+	snap := `{"entities": [{"entity": {"id": 1}}, {"entity": {"id": 2}}]}`
+	if _, err := s.ReadFrom(bytes.NewBufferString(snap)); err == nil {
+		t.Errorf("ReadFrom() didn't fail to save")
+	}
+	other := filepath.Join(t.TempDir(), "other.json")
+	if err := os.WriteFile(other, []byte(snap), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(other); err == nil {
+		t.Errorf("Load() didn't fail to save")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d after a failed restore", s.Len())
+	}
+
+	if _, err := Open[product](path); err == nil {
+		t.Errorf("Open() of a directory didn't fail")
+	}
+}
@@ -0,0 +1,455 @@
+// Package memstore is a concurrency safe in memory manager,
+// for prototypes, tests and small services
+//
+// Entities are kept in their JSON form: they are never shared with the
+// caller, and the fields encoding/json ignores are not kept
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+	"github.com/rs/xid"
+)
+
+var (
+	// ErrNotFound is returned for ids of no entity
+	ErrNotFound = errors.New("entity not found")
+	// ErrConflict is returned when the generated id is already taken
+	ErrConflict = errors.New("id already taken")
+	// ErrVersionMismatch is returned when the entity is not at the
+	// version the update expects, see crud.IfMatchFromContext
+	ErrVersionMismatch = errors.New("entity version mismatch")
+)
+
+// Store is a crud.MgrI of the entities *E, E being a struct with an
+// "id" JSON field, set by Create
+// It also is a crud.Counter, crud.Exister, crud.Versioner and
+// crud.LastModifier, and lists are filtered, sorted and paginated
+// according to its crud.QuerySpec
+type Store[E any] struct {
+	mu      sync.RWMutex
+	records map[string]*record
+	seq     uint64
+	lastID  int64
+	path    string
+	idField crud.StructJSONField
+	o       options
+}
+
+// record is an entity in its JSON form
+type record struct {
+	id       crud.ID
+	data     []byte
+	doc      map[string]interface{}
+	modified time.Time
+	seq      uint64
+}
+
+type options struct {
+	newID      func() crud.ID
+	parseID    func(string) (crud.ID, error)
+	sequential bool
+	spec       *crud.QuerySpec
+	now        func() time.Time
+}
+
+// Option configures a Store
+type Option func(*options)
+
+// WithIDs generates the ids with newID and parses them with parseID,
+// the ids are xids by default
+func WithIDs(
+	newID func() crud.ID,
+	parseID func(string) (crud.ID, error),
+) Option {
+	return func(o *options) {
+		o.newID = newID
+		o.parseID = parseID
+	}
+}
+
+// WithSequentialIDs numbers the entities from 1, as crud.Int64ID ids
+func WithSequentialIDs() Option {
+	return func(o *options) {
+		o.sequential = true
+		o.parseID = func(s string) (crud.ID, error) {
+			return crud.ParseInt64ID(s)
+		}
+	}
+}
+
+// WithQuerySpec restricts the list modifiers to spec,
+// by default all the JSON fields can be filtered and sorted on
+func WithQuerySpec(spec crud.QuerySpec) Option {
+	return func(o *options) {
+		o.spec = &spec
+	}
+}
+
+// WithClock sets the clock of the modification times, time.Now by default
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// New returns an empty Store
+// It panics if E is not a struct with an "id" JSON field
+func New[E any](opts ...Option) *Store[E] {
+	o := options{
+		newID:   func() crud.ID { return xid.New() },
+		parseID: func(s string) (crud.ID, error) { return crud.ParseXID(s) },
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t := reflect.TypeOf((*E)(nil)).Elem()
+	var idField crud.StructJSONField
+	found := false
+	for _, f := range crud.JSONFields(t) {
+		if f.Name == "id" {
+			idField, found = f, true
+		}
+	}
+	if t.Kind() != reflect.Struct || !found {
+		panic(fmt.Sprintf("memstore: %v is not a struct with an id", t))
+	}
+
+	if o.spec == nil {
+		o.spec = defaultSpec(t)
+	}
+
+	return &Store[E]{
+		records: make(map[string]*record),
+		idField: idField,
+		o:       o,
+	}
+}
+
+// Open returns a Store persisted to the snapshot file path,
+// loaded from it if it exists, and saved to it after every change
+func Open[E any](path string, opts ...Option) (*Store[E], error) {
+	s := New[E](opts...)
+	if err := s.Load(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	s.path = path
+	return s, nil
+}
+
+// ParseID parses the ids of the store
+func (s *Store[E]) ParseID(id string) (crud.ID, error) {
+	return s.o.parseID(id)
+}
+
+// NewEmptyEntity returns a new *E
+func (s *Store[E]) NewEmptyEntity() interface{} {
+	return new(E)
+}
+
+// Create stores a copy of e under a new id, and returns it
+func (s *Store[E]) Create(
+	ctx context.Context,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	ec, err := s.cast(e)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID()
+	if _, ok := s.records[id.String()]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrConflict, id)
+	}
+	c := *ec
+	if err := s.setID(&c, id); err != nil {
+		return nil, err
+	}
+	rec, err := s.encode(id, &c)
+	if err != nil {
+		return nil, err
+	}
+	s.seq++
+	rec.seq = s.seq
+	if err := s.commit(id.String(), rec); err != nil {
+		return nil, err
+	}
+	return s.decode(rec)
+}
+
+// Get returns a copy of the entity id
+func (s *Store[E]) Get(ctx context.Context, id crud.ID) (interface{}, error) {
+	s.mu.RLock()
+	rec, ok := s.records[id.String()]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.decode(rec)
+}
+
+// Exists returns true if the entity id exists
+func (s *Store[E]) Exists(ctx context.Context, id crud.ID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.records[id.String()]
+	return ok, nil
+}
+
+// Update replaces the entity id by a copy of e, and returns it
+func (s *Store[E]) Update(
+	ctx context.Context,
+	id crud.ID,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	ec, err := s.cast(e)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.current(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c := *ec
+	return s.replace(old, &c)
+}
+
+// PartialUpdate merges pud into the entity id
+func (s *Store[E]) PartialUpdate(
+	ctx context.Context,
+	id crud.ID,
+	pud crud.PartialUpdateData,
+	pl io.Reader,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.current(ctx, id)
+	if err != nil {
+		return err
+	}
+	e, err := s.decode(old)
+	if err != nil {
+		return err
+	}
+	target := new(E)
+	if err := pud.ApplyTo(e, target); err != nil {
+		return err
+	}
+	_, err = s.replace(old, target)
+	return err
+}
+
+// Delete deletes the entity id
+func (s *Store[E]) Delete(ctx context.Context, id crud.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.current(ctx, id); err != nil {
+		return err
+	}
+	return s.commit(id.String(), nil)
+}
+
+// Version returns the hash of the JSON form of e
+func (s *Store[E]) Version(e interface{}) string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LastModified returns when the entity e was last created or changed
+func (s *Store[E]) LastModified(e interface{}) time.Time {
+	id, ok := crud.EntityID(e)
+	if !ok {
+		return time.Time{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rec, ok := s.records[id]; ok {
+		return rec.modified
+	}
+	return time.Time{}
+}
+
+// MapErrorToHTTPError maps the errors of the store,
+// ErrNotFound to 404, ErrConflict to 409 and ErrVersionMismatch to 412
+func (s *Store[E]) MapErrorToHTTPError(e error) *gohttperror.ErrResponse {
+	switch {
+	case errors.Is(e, ErrNotFound):
+		return gohttperror.ErrNotFound
+	case errors.Is(e, ErrConflict):
+		return &gohttperror.ErrResponse{
+			Err:            e,
+			HTTPStatusCode: http.StatusConflict,
+			StatusText:     "Conflict",
+		}
+	case errors.Is(e, ErrVersionMismatch):
+		return &gohttperror.ErrResponse{
+			Err:            e,
+			HTTPStatusCode: http.StatusPreconditionFailed,
+			StatusText:     "Precondition failed",
+		}
+	case errors.Is(e, crud.ErrEntityType):
+		return gohttperror.ErrBadRequest(e)
+	default:
+		return gohttperror.ErrInternal(e)
+	}
+}
+
+// Len returns the number of entities
+func (s *Store[E]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+func (s *Store[E]) cast(e interface{}) (*E, error) {
+	ec, ok := e.(*E)
+	if !ok || ec == nil {
+		return nil, fmt.Errorf("%w: got %T, want %T", crud.ErrEntityType, e, ec)
+	}
+	return ec, nil
+}
+
+// nextID returns a new id, under the write lock
+func (s *Store[E]) nextID() crud.ID {
+	if s.o.sequential {
+		s.lastID++
+		return crud.Int64ID(s.lastID)
+	}
+	return s.o.newID()
+}
+
+// setID sets the id field of e to id
+func (s *Store[E]) setID(e *E, id crud.ID) error {
+	f := reflect.ValueOf(e).Elem().FieldByIndex(s.idField.Index)
+	v := reflect.ValueOf(id)
+	switch {
+	case v.Type().AssignableTo(f.Type()):
+		f.Set(v)
+	case v.Type().ConvertibleTo(f.Type()) && v.Kind() != reflect.String &&
+		f.Kind() != reflect.String:
+		f.Set(v.Convert(f.Type()))
+	case f.Kind() == reflect.String:
+		f.SetString(id.String())
+	default:
+		return fmt.Errorf("memstore: cannot set a %T id to a %v field",
+			id, f.Type())
+	}
+	return nil
+}
+
+// current returns the record of id, under the write lock,
+// checking its version against the one ctx expects
+func (s *Store[E]) current(ctx context.Context, id crud.ID) (*record, error) {
+	rec, ok := s.records[id.String()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if version, ok := crud.IfMatchFromContext(ctx); ok {
+		e, err := s.decode(rec)
+		if err != nil {
+			return nil, err
+		}
+		if s.Version(e) != version {
+			return nil, ErrVersionMismatch
+		}
+	}
+	return rec, nil
+}
+
+// replace stores e in place of old, keeping its id and position
+func (s *Store[E]) replace(old *record, e *E) (interface{}, error) {
+	if err := s.setID(e, old.id); err != nil {
+		return nil, err
+	}
+	rec, err := s.encode(old.id, e)
+	if err != nil {
+		return nil, err
+	}
+	rec.seq = old.seq
+	if err := s.commit(old.id.String(), rec); err != nil {
+		return nil, err
+	}
+	return s.decode(rec)
+}
+
+// commit sets the record of key, deleting it if rec is nil,
+// and saves the snapshot of persisted stores, rolling back on failure
+func (s *Store[E]) commit(key string, rec *record) error {
+	old, existed := s.records[key]
+	if rec == nil {
+		delete(s.records, key)
+	} else {
+		s.records[key] = rec
+	}
+	if err := s.persist(); err != nil {
+		if existed {
+			s.records[key] = old
+		} else {
+			delete(s.records, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store[E]) encode(id crud.ID, e *E) (*record, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeDoc(b)
+	if err != nil {
+		return nil, err
+	}
+	return &record{id: id, data: b, doc: doc, modified: s.o.now()}, nil
+}
+
+func (s *Store[E]) decode(rec *record) (*E, error) {
+	e := new(E)
+	if err := json.Unmarshal(rec.data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// decodeDoc decodes the JSON object b, keeping the numbers exact
+func decodeDoc(b []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
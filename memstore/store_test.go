@@ -0,0 +1,246 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/rest"
+	"github.com/rs/xid"
+)
+
+type item struct {
+	ID    xid.ID   `json:"id"`
+	Name  string   `json:"name"`
+	Price int      `json:"price"`
+	Tags  []string `json:"tags,omitempty"`
+	Note  string   `json:"-"`
+}
+
+type counter struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+var _ interface {
+	crud.MgrI
+	crud.Counter
+	crud.Exister
+	crud.Versioner
+	crud.LastModifier
+	crud.IDParser
+} = (*Store[item])(nil)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := New[item]()
+
+	created, err := s.Create(ctx, &item{Name: "pen", Price: 2, Note: "x"}, nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	it := created.(*item)
+	if it.ID.IsNil() || it.Note != "" {
+		t.Fatalf("Create() = %+v", it)
+	}
+
+	// the stored entity is not shared with the caller
+	it.Name = "changed"
+	got, err := s.Get(ctx, it.ID)
+	if err != nil || got.(*item).Name != "pen" {
+		t.Fatalf("Get() = %+v, %v", got, err)
+	}
+
+	other := xid.New()
+	updated, err := s.Update(ctx, it.ID, &item{ID: other, Name: "ink"}, nil)
+	if err != nil || updated.(*item).ID != it.ID {
+		t.Fatalf("Update() = %+v, %v", updated, err)
+	}
+	_, err = s.Update(ctx, other, &item{}, nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() of a missing entity error = %v", err)
+	}
+
+	err = s.PartialUpdate(ctx, it.ID,
+		crud.PartialUpdateData{"price": 3, "tags": []interface{}{"a"}}, nil)
+	if err != nil {
+		t.Fatalf("PartialUpdate() error = %v", err)
+	}
+	got, _ = s.Get(ctx, it.ID)
+	if p := got.(*item); p.Name != "ink" || p.Price != 3 || len(p.Tags) != 1 {
+		t.Errorf("PartialUpdate() stored %+v", p)
+	}
+	err = s.PartialUpdate(ctx, it.ID, crud.PartialUpdateData{"nope": 1}, nil)
+	if !errors.Is(err, crud.ErrInvalidPatch) {
+		t.Errorf("PartialUpdate() of an unknown field error = %v", err)
+	}
+
+	if ok, _ := s.Exists(ctx, it.ID); !ok {
+		t.Errorf("Exists() = false")
+	}
+	if s.LastModified(got).IsZero() {
+		t.Errorf("LastModified() is zero")
+	}
+
+	if err := s.Delete(ctx, it.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(ctx, it.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v", err)
+	}
+	if _, err := s.Get(ctx, it.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a deleted entity error = %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d", s.Len())
+	}
+
+	_, err = s.Create(ctx, &counter{}, nil)
+	if !errors.Is(err, crud.ErrEntityType) {
+		t.Errorf("Create() of another type error = %v", err)
+	}
+}
+
+func TestStoreIfMatch(t *testing.T) {
+	ctx := context.Background()
+	s := New[item]()
+	created, _ := s.Create(ctx, &item{Name: "pen"}, nil)
+	id := created.(*item).ID
+	version := s.Version(created)
+
+	stale := crud.WithIfMatch(ctx, "stale")
+	_, err := s.Update(stale, id, &item{}, nil)
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Update() with a stale version error = %v", err)
+	}
+	if err := s.Delete(stale, id); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Delete() with a stale version error = %v", err)
+	}
+
+	current := crud.WithIfMatch(ctx, version)
+	updated, err := s.Update(current, id, &item{Name: "ink"}, nil)
+	if err != nil {
+		t.Fatalf("Update() with the current version error = %v", err)
+	}
+	if s.Version(updated) == version {
+		t.Errorf("Version() didn't change")
+	}
+	err = s.PartialUpdate(current, id, crud.PartialUpdateData{"price": 1}, nil)
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("PartialUpdate() with the old version error = %v", err)
+	}
+	if me := s.MapErrorToHTTPError(err); me.HTTPStatusCode != 412 {
+		t.Errorf("MapErrorToHTTPError() = %d", me.HTTPStatusCode)
+	}
+}
+
+func TestStoreSequentialIDs(t *testing.T) {
+	ctx := context.Background()
+	s := New[counter](WithSequentialIDs())
+
+	for i := int64(1); i <= 3; i++ {
+		e, err := s.Create(ctx, &counter{Name: "c"}, nil)
+		if err != nil || e.(*counter).ID != i {
+			t.Fatalf("Create() = %+v, %v", e, err)
+		}
+	}
+	id, err := s.ParseID("2")
+	if err != nil || id != crud.Int64ID(2) {
+		t.Fatalf("ParseID() = %v, %v", id, err)
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+}
+
+func TestNewPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("New() didn't panic")
+		}
+	}()
+	New[struct{ Name string }]()
+}
+
+func TestStoreConcurrency(t *testing.T) {
+	ctx := context.Background()
+	s := New[item]()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				e, err := s.Create(ctx, &item{Name: "pen", Price: j}, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				id := e.(*item).ID
+				pud := crud.PartialUpdateData{"price": j + 1}
+				if err := s.PartialUpdate(ctx, id, pud, nil); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.GetList(ctx, crud.ListModifiers{
+					"price[gte]": {"10"}, "orderby": {"price DESC"},
+				}); err != nil {
+					t.Error(err)
+				}
+				if j%2 == 0 {
+					if err := s.Delete(ctx, id); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.Len() != 8*25 {
+		t.Errorf("Len() = %d, want %d", s.Len(), 8*25)
+	}
+}
+
+func TestStoreREST(t *testing.T) {
+	r := chi.NewRouter()
+	rest.Mount(r, New[item](), rest.WithPath("/items"))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, name := range []string{"pen", "ink", "pad"} {
+		if rr := do("POST", "/items", `{"name":"`+name+`"}`); rr.Code != 201 {
+			t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	rr := do("GET", "/items?orderby=name&limit=2", "")
+	if rr.Code != http.StatusOK ||
+		!strings.Contains(rr.Body.String(), `"name":"ink"`) ||
+		strings.Contains(rr.Body.String(), `"name":"pen"`) ||
+		!strings.Contains(rr.Header().Get("Link"), `rel="next"`) ||
+		rr.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = do("GET", "/items?unknown=1", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET with an unknown filter returned %d", rr.Code)
+	}
+	rr = do("GET", "/items/"+xid.New().String(), "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing entity returned %d", rr.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MergePatch returns doc, a decoded JSON value, with patch merged into it
+// following RFC 7386: objects are merged recursively, null removes a key,
+// and any other value replaces the target
+// The objects of doc are modified in place
+func MergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = MergePatch(d[k], v)
+	}
+	return d
+}
+
+// Check returns an error wrapping ErrInvalidPatch if d sets a field
+// that is not a JSON field of the type of e, nested objects included
+// Anything under a map or an interface is accepted
+func (d PartialUpdateData) Check(e interface{}) error {
+	return checkMergePatch(reflect.TypeOf(e), d, Pointer{})
+}
+
+func checkMergePatch(
+	t reflect.Type,
+	patch map[string]interface{},
+	path Pointer,
+) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := append(path[:len(path):len(path)], k)
+		f, ok := JSONField(t, k)
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidPatch, p.String())
+		}
+		if sub, ok := patch[k].(map[string]interface{}); ok {
+			if err := checkMergePatch(f.Type, sub, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyTo merges d into the JSON form of e, and decodes the result
+// into target, usually a new empty entity, so that fields removed
+// with null get their zero value
+func (d PartialUpdateData) ApplyTo(e interface{}, target interface{}) error {
+	if err := d.Check(e); err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	v, err := decodeJSON(doc)
+	if err != nil {
+		return err
+	}
+	merged, err := json.Marshal(MergePatch(v, map[string]interface{}(d)))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(merged, target); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnprocessableEntity, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples of RFC 7386 appendix A
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var doc, patch, want interface{}
+			_ = json.Unmarshal([]byte(tt.doc), &doc)
+			_ = json.Unmarshal([]byte(tt.patch), &patch)
+			_ = json.Unmarshal([]byte(tt.want), &want)
+
+			if got := MergePatch(doc, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("MergePatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPartialUpdateDataApplyTo(t *testing.T) {
+	e := &fieldsEntity{
+		fieldsBase: fieldsBase{ID: "a"},
+		Status:     1,
+		Owner:      &fieldsOwner{Name: "pol", Email: "pol@lux.com"},
+		Extra:      map[string]interface{}{"k": 1},
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    *fieldsEntity
+		wantErr error
+	}{
+		{
+			name:  "nested objects and null",
+			patch: `{"status":2,"owner":{"email":null},"extra":{"k":null,"x":1}}`,
+			want: &fieldsEntity{
+				fieldsBase: fieldsBase{ID: "a"},
+				Status:     2,
+				Owner:      &fieldsOwner{Name: "pol"},
+				Extra:      map[string]interface{}{"x": 1.0},
+			},
+		},
+		{
+			name:  "remove an object",
+			patch: `{"owner":null}`,
+			want: &fieldsEntity{
+				fieldsBase: fieldsBase{ID: "a"},
+				Status:     1,
+				Extra:      map[string]interface{}{"k": 1.0},
+			},
+		},
+		{
+			name:    "unknown field",
+			patch:   `{"owner":{"phone":"1"}}`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "ignored field",
+			patch:   `{"Secret":"1"}`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "wrong type",
+			patch:   `{"status":"2"}`,
+			wantErr: ErrUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d PartialUpdateData
+			if err := json.Unmarshal([]byte(tt.patch), &d); err != nil {
+				t.Fatal(err)
+			}
+
+			got := &fieldsEntity{}
+			err := d.ApplyTo(e, got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyTo() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplyTo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,18 +4,73 @@ import (
 	"context"
 	"io"
 
-	"github.com/rs/xid"
 	"github.com/induzo/gohttperror"
 )
 
-// MgrI is the interface to initialize the new entity mgr
-type MgrI interface {
+// EntityFactory creates the empty entities payloads are decoded into
+type EntityFactory interface {
 	NewEmptyEntity() interface{}
+}
+
+// Creator is a manager able to create entities
+type Creator interface {
+	EntityFactory
 	Create(context.Context, interface{}, io.Reader) (interface{}, error)
-	Delete(context.Context, xid.ID) error
-	Get(context.Context, xid.ID) (interface{}, error)
+}
+
+// Getter is a manager able to get a single entity
+type Getter interface {
+	Get(context.Context, ID) (interface{}, error)
+}
+
+// Lister is a manager able to list entities
+type Lister interface {
 	GetList(context.Context, ListModifiers) (interface{}, error)
-	Update(context.Context, xid.ID, interface{}, io.Reader) (interface{}, error)
-	PartialUpdate(context.Context, xid.ID, PartialUpdateData, io.Reader) error
+}
+
+// Exister is a manager able to tell if an entity exists
+// without loading it, for HEAD requests
+type Exister interface {
+	Exists(context.Context, ID) (bool, error)
+}
+
+// Counter is a manager able to count the entities of a list,
+// it gets the same list modifiers as GetList, and ignores the pagination ones
+type Counter interface {
+	Count(context.Context, ListModifiers) (int64, error)
+}
+
+// Updater is a manager able to replace an entity
+type Updater interface {
+	EntityFactory
+	Update(context.Context, ID, interface{}, io.Reader) (interface{}, error)
+}
+
+// Patcher is a manager able to partially update an entity
+type Patcher interface {
+	PartialUpdate(context.Context, ID, PartialUpdateData, io.Reader) error
+}
+
+// Deleter is a manager able to delete an entity
+type Deleter interface {
+	Delete(context.Context, ID) error
+}
+
+// ErrorMapper is a manager able to map its errors to http errors
+type ErrorMapper interface {
 	MapErrorToHTTPError(error) *gohttperror.ErrResponse
 }
+
+// MgrI is the interface to initialize the new entity mgr
+// ids are xids unless the manager also implements IDParser
+// A manager only implementing some of the operations
+// can implement only the interfaces MgrI is made of
+type MgrI interface {
+	Creator
+	Getter
+	Lister
+	Updater
+	Patcher
+	Deleter
+	ErrorMapper
+}
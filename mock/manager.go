@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/induzo/crud"
 	"github.com/induzo/gohttperror"
@@ -20,7 +22,11 @@ var (
 	ErrForbidden = errors.New("forbidden")
 )
 
-// Mgr is a mock for the mgr interface
+// Mgr is a mock for the mgr interface, safe for concurrent use
+// It records its calls, and answers them with the responses scripted
+// with Script, or as usual: from EntityList, failing if Want*Error
+// Set the fields before using it, EntityList being only safe to use
+// when no call is running
 type Mgr struct {
 	WantCreateError        bool
 	WantDeleteError        bool
@@ -29,6 +35,12 @@ type Mgr struct {
 	WantUpdateError        bool
 	WantPartialUpdateError bool
 	EntityList             map[xid.ID]*Entity
+	// Latency delays every call
+	Latency time.Duration
+
+	mu      sync.Mutex
+	calls   []Call
+	scripts map[Op][]Response
 }
 
 func NewMgr() *Mgr {
@@ -37,6 +49,13 @@ func NewMgr() *Mgr {
 	}
 }
 
+// key converts id to the xid keys of EntityList,
+// ids of another type end up as the nil xid, which is never found
+func key(id crud.ID) xid.ID {
+	k, _ := id.(xid.ID)
+	return k
+}
+
 func (m *Mgr) NewEmptyEntity() interface{} {
 	return &Entity{}
 }
@@ -46,6 +65,13 @@ func (m *Mgr) Create(
 	e interface{},
 	pl io.Reader,
 ) (interface{}, error) {
+	r, err := m.call(ctx, Call{Op: OpCreate, Entity: e}, pl)
+	if err != nil {
+		return nil, err
+	}
+	if r.Entity != nil {
+		return r.Entity, nil
+	}
 	if m.WantCreateError {
 		return nil, fmt.Errorf("Error create")
 	}
@@ -54,40 +80,69 @@ func (m *Mgr) Create(
 		return nil,
 			fmt.Errorf("Mgr Create: impossible to cast e to Entity")
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	ec.ID = xid.New()
 	m.EntityList[ec.ID] = ec
 	return ec, nil
 }
 
-func (m *Mgr) Delete(ctx context.Context, id xid.ID) error {
+func (m *Mgr) Delete(ctx context.Context, id crud.ID) error {
+	if _, err := m.call(ctx, Call{Op: OpDelete, ID: id}, nil); err != nil {
+		return err
+	}
 	if m.WantDeleteError {
 		return fmt.Errorf("Error delete")
 	}
-	if _, ok := m.EntityList[id]; !ok {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.EntityList[key(id)]; !ok {
 		return ErrNotFound
 	}
 
-	delete(m.EntityList, id)
+	delete(m.EntityList, key(id))
 	return nil
 }
 
-func (m *Mgr) Get(ctx context.Context, id xid.ID) (interface{}, error) {
+func (m *Mgr) Get(ctx context.Context, id crud.ID) (interface{}, error) {
+	r, err := m.call(ctx, Call{Op: OpGet, ID: id}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Entity != nil {
+		return r.Entity, nil
+	}
 	if m.WantGetError {
 		return nil, fmt.Errorf("Error get")
 	}
-	if ent, ok := m.EntityList[id]; ok {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ent, ok := m.EntityList[key(id)]; ok {
 		return ent, nil
 	}
 	return nil, ErrNotFound
 }
 
 func (m *Mgr) GetList(
-	context.Context,
-	crud.ListModifiers,
+	ctx context.Context,
+	lm crud.ListModifiers,
 ) (interface{}, error) {
+	r, err := m.call(ctx, Call{Op: OpGetList, Modifiers: lm}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.List != nil {
+		return r.List, nil
+	}
 	if m.WantGetListError {
 		return nil, fmt.Errorf("Error getlist")
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if len(m.EntityList) == 0 {
 		return nil, ErrNotFound
 	}
@@ -102,43 +157,64 @@ func (m *Mgr) GetList(
 
 func (m *Mgr) Update(
 	ctx context.Context,
-	id xid.ID,
+	id crud.ID,
 	newE interface{},
 	pl io.Reader,
 ) (interface{}, error) {
+	r, err := m.call(ctx, Call{Op: OpUpdate, ID: id, Entity: newE}, pl)
+	if err != nil {
+		return nil, err
+	}
+	if r.Entity != nil {
+		return r.Entity, nil
+	}
 	if m.WantUpdateError {
 		return nil, fmt.Errorf("Error update")
 	}
-	_, ok := m.EntityList[id]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.EntityList[key(id)]
 	if !ok {
 		return nil, ErrNotFound
 	}
 
 	newEC, okC := newE.(*Entity)
 	if !okC {
-		return ErrBadRequest, nil
+		return nil, ErrBadRequest
 	}
-	m.EntityList[id] = newEC
+	m.EntityList[key(id)] = newEC
 	return newEC, nil
 }
 
 func (m *Mgr) PartialUpdate(
 	ctx context.Context,
-	id xid.ID,
+	id crud.ID,
 	pud crud.PartialUpdateData,
 	pl io.Reader,
 ) error {
+	c := Call{Op: OpPartialUpdate, ID: id, Patch: pud}
+	if _, err := m.call(ctx, c, pl); err != nil {
+		return err
+	}
 	if m.WantPartialUpdateError {
 		return fmt.Errorf("Error partial update")
 	}
-	if _, ok := pud["status_id"]; !ok {
+	sid, ok := pud["status_id"].(float64)
+	if !ok {
 		return ErrBadRequest
 	}
-	if _, ok := m.EntityList[id]; !ok {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.EntityList[key(id)]; !ok {
 		return ErrNotFound
 	}
 
-	m.EntityList[id].StatusID = int(pud["status_id"].(float64))
+	// a copy, as the entity may be in use by another call
+	ent := *m.EntityList[key(id)]
+	ent.StatusID = int(sid)
+	m.EntityList[key(id)] = &ent
 
 	return nil
 }
@@ -147,6 +223,8 @@ func (m *Mgr) MapErrorToHTTPError(e error) *gohttperror.ErrResponse {
 	switch e {
 	case ErrNotFound:
 		return gohttperror.ErrNotFound
+	case ErrBadRequest:
+		return gohttperror.ErrBadRequest(e)
 	case ErrForbidden:
 		return gohttperror.ErrForbidden(e)
 	default:
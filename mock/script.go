@@ -0,0 +1,173 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+// Op is an operation of the manager
+type Op string
+
+// The operations of Mgr
+const (
+	OpCreate        Op = "Create"
+	OpGet           Op = "Get"
+	OpGetList       Op = "GetList"
+	OpUpdate        Op = "Update"
+	OpPartialUpdate Op = "PartialUpdate"
+	OpDelete        Op = "Delete"
+)
+
+// Call is a recorded call to Mgr
+type Call struct {
+	Op        Op
+	ID        crud.ID
+	Entity    interface{}
+	Modifiers crud.ListModifiers
+	Patch     crud.PartialUpdateData
+	// Payload is what was read from the raw payload
+	Payload []byte
+}
+
+// Response is the scripted response to a call
+// A response without Err, Entity nor List lets Mgr answer as usual
+type Response struct {
+	// Err is returned by the call
+	Err error
+	// Entity is returned by Create, Get and Update
+	Entity interface{}
+	// List is returned by GetList
+	List interface{}
+	// Delay is added to the latency of Mgr before the call returns
+	Delay time.Duration
+}
+
+// TB is the part of testing.TB the assertions use
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Script queues responses to the next calls of op, one per call,
+// the calls after them being answered as usual
+func (m *Mgr) Script(op Op, responses ...Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.scripts == nil {
+		m.scripts = make(map[Op][]Response)
+	}
+	m.scripts[op] = append(m.scripts[op], responses...)
+}
+
+// Calls returns the recorded calls, in order
+func (m *Mgr) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the recorded calls of op, in order
+func (m *Mgr) CallsTo(op Op) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var calls []Call
+	for _, c := range m.calls {
+		if c.Op == op {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls and the responses left in the scripts
+func (m *Mgr) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.scripts = nil
+}
+
+// AssertCalled fails t unless op was called n times
+func (m *Mgr) AssertCalled(t TB, op Op, n int) {
+	t.Helper()
+	if got := len(m.CallsTo(op)); got != n {
+		t.Errorf("%s called %d times, want %d", op, got, n)
+	}
+}
+
+// AssertCalledWith fails t unless op was called with the id
+func (m *Mgr) AssertCalledWith(t TB, op Op, id crud.ID) {
+	t.Helper()
+	var ids []string
+	for _, c := range m.CallsTo(op) {
+		if c.ID != nil && c.ID.String() == id.String() {
+			return
+		}
+		if c.ID != nil {
+			ids = append(ids, c.ID.String())
+		}
+	}
+	t.Errorf("%s not called with %s, but with %v", op, id, ids)
+}
+
+// AssertScriptsDone fails t if scripted responses were not used
+func (m *Mgr) AssertScriptsDone(t TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range []Op{
+		OpCreate, OpGet, OpGetList, OpUpdate, OpPartialUpdate, OpDelete,
+	} {
+		if n := len(m.scripts[op]); n > 0 {
+			t.Errorf("%d scripted responses to %s left", n, op)
+		}
+	}
+}
+
+// call records c, reading the payload pl, and returns its scripted
+// response, after the latency
+// The error is the one of the response, or of ctx if done before
+func (m *Mgr) call(
+	ctx context.Context,
+	c Call,
+	pl io.Reader,
+) (Response, error) {
+	if pl != nil {
+		b, err := io.ReadAll(pl)
+		if err != nil {
+			return Response{}, fmt.Errorf("reading the payload: %w", err)
+		}
+		c.Payload = b
+	}
+	if c.Modifiers != nil {
+		lm := make(crud.ListModifiers, len(c.Modifiers))
+		for k, v := range c.Modifiers {
+			lm[k] = append([]string(nil), v...)
+		}
+		c.Modifiers = lm
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, c)
+	var r Response
+	if script := m.scripts[c.Op]; len(script) > 0 {
+		r, m.scripts[c.Op] = script[0], script[1:]
+	}
+	delay := m.Latency + r.Delay
+	m.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return r, ctx.Err()
+		}
+	}
+	return r, r.Err
+}
@@ -0,0 +1,127 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+	"github.com/rs/xid"
+)
+
+// TypedMgr is a mock for the type safe crud.Manager interface
+type TypedMgr struct {
+	WantCreateError bool
+	WantUpdateError bool
+	EntityList      map[xid.ID]*Entity
+}
+
+func NewTypedMgr() *TypedMgr {
+	return &TypedMgr{
+		EntityList: make(map[xid.ID]*Entity),
+	}
+}
+
+func (m *TypedMgr) ParseID(s string) (xid.ID, error) {
+	return crud.ParseXID(s)
+}
+
+func (m *TypedMgr) NewEmptyEntity() *Entity {
+	return &Entity{}
+}
+
+func (m *TypedMgr) Create(
+	ctx context.Context,
+	e *Entity,
+	pl io.Reader,
+) (*Entity, error) {
+	if m.WantCreateError {
+		return nil, fmt.Errorf("Error create")
+	}
+	e.ID = xid.New()
+	m.EntityList[e.ID] = e
+	return e, nil
+}
+
+func (m *TypedMgr) Delete(ctx context.Context, id xid.ID) error {
+	if _, ok := m.EntityList[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.EntityList, id)
+	return nil
+}
+
+func (m *TypedMgr) Get(ctx context.Context, id xid.ID) (*Entity, error) {
+	if ent, ok := m.EntityList[id]; ok {
+		return ent, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *TypedMgr) GetList(
+	context.Context,
+	crud.ListModifiers,
+) ([]*Entity, error) {
+	if len(m.EntityList) == 0 {
+		return nil, ErrNotFound
+	}
+
+	v := make([]*Entity, 0, len(m.EntityList))
+	for _, value := range m.EntityList {
+		v = append(v, value)
+	}
+
+	return v, nil
+}
+
+func (m *TypedMgr) Update(
+	ctx context.Context,
+	id xid.ID,
+	newE *Entity,
+	pl io.Reader,
+) (*Entity, error) {
+	if m.WantUpdateError {
+		return nil, fmt.Errorf("Error update")
+	}
+	if _, ok := m.EntityList[id]; !ok {
+		return nil, ErrNotFound
+	}
+
+	newE.ID = id
+	m.EntityList[id] = newE
+	return newE, nil
+}
+
+func (m *TypedMgr) PartialUpdate(
+	ctx context.Context,
+	id xid.ID,
+	pud crud.PartialUpdateData,
+	pl io.Reader,
+) error {
+	sid, ok := pud["status_id"].(float64)
+	if !ok {
+		return ErrBadRequest
+	}
+	if _, ok := m.EntityList[id]; !ok {
+		return ErrNotFound
+	}
+
+	m.EntityList[id].StatusID = int(sid)
+
+	return nil
+}
+
+func (m *TypedMgr) MapErrorToHTTPError(e error) *gohttperror.ErrResponse {
+	switch e {
+	case ErrNotFound:
+		return gohttperror.ErrNotFound
+	case ErrBadRequest:
+		return gohttperror.ErrBadRequest(e)
+	case ErrForbidden:
+		return gohttperror.ErrForbidden(e)
+	default:
+		return gohttperror.ErrInternal(e)
+	}
+}
@@ -0,0 +1,14 @@
+package crud
+
+// Page is a page of a list, GetList can return it instead of the bare list
+// to let the client page through the list with cursors
+// Cursors are opaque to the client, the REST wrapper signs them,
+// and they come back to GetList as the page_token list modifier
+type Page struct {
+	// Items are the entities of the page
+	Items interface{}
+	// NextCursor is where the next page starts, empty on the last page
+	NextCursor string
+	// PrevCursor is where the previous page starts, empty on the first page
+	PrevCursor string
+}
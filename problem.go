@@ -0,0 +1,97 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Problem is an error carrying RFC 7807 problem details,
+// a manager can return it, or wrap it, to control its error response
+type Problem struct {
+	// Type is an URI identifying the problem type, about:blank by default
+	Type string
+	// Title is a short summary of the problem type
+	Title string
+	// Status is the HTTP status code
+	Status int
+	// Detail explains this occurrence of the problem
+	Detail string
+	// Instance is an URI identifying this occurrence of the problem
+	Instance string
+	// Extensions are additional members of the problem details
+	Extensions map[string]interface{}
+	// Err is the underlying error, it is never rendered
+	Err error
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%d %s", p.Status, p.Title)
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.Err != nil {
+		msg += ": " + p.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// MarshalJSON renders the problem details as an
+// application/problem+json document, extensions being top level members
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		doc[k] = v
+	}
+
+	for k, v := range map[string]string{
+		"type":     p.Type,
+		"title":    p.Title,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	} {
+		if v != "" {
+			doc[k] = v
+		} else {
+			delete(doc, k)
+		}
+	}
+	if p.Status != 0 {
+		doc["status"] = p.Status
+	} else {
+		delete(doc, "status")
+	}
+
+	return json.Marshal(doc)
+}
+
+// JSONSchema returns the schema of problem details,
+// the errors member listing the invalid fields of 422 responses
+func (p *Problem) JSONSchema() *Schema {
+	str := func(d string) *Schema {
+		return &Schema{Type: SchemaType{"string"}, Description: d}
+	}
+	// inlined, as the reflector of the caller is not at hand
+	fieldError := (&SchemaReflector{}).structSchema(
+		reflect.TypeOf(FieldError{}),
+	)
+	return &Schema{
+		Type: SchemaType{"object"},
+		Properties: map[string]*Schema{
+			"type":     str("URI identifying the problem type"),
+			"title":    str("short summary of the problem type"),
+			"status":   {Type: SchemaType{"integer"}},
+			"detail":   str("explanation of this occurrence of the problem"),
+			"instance": str("URI identifying this occurrence of the problem"),
+			"errors": {
+				Type:  SchemaType{"array"},
+				Items: fieldError,
+			},
+		},
+	}
+}
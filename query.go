@@ -0,0 +1,268 @@
+package crud
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The list modifiers with a special meaning in a Query,
+// all the others are filters
+const (
+	ParamOrderBy   = "orderby"
+	ParamLimit     = "limit"
+	ParamOffset    = "offset"
+	ParamPageToken = "page_token"
+	// ParamCount=false disables the count of a list, it is not in Query
+	ParamCount = "count"
+	// ParamFields=id,owner.name restricts the fields of the entities
+	ParamFields = "fields"
+)
+
+// Operator is the comparison of a Filter
+type Operator string
+
+// The filter operators, in list modifiers they are written field[op]=value,
+// field=value being the same as field[eq]=value
+const (
+	Eq       Operator = "eq"
+	Ne       Operator = "ne"
+	Gt       Operator = "gt"
+	Gte      Operator = "gte"
+	Lt       Operator = "lt"
+	Lte      Operator = "lte"
+	In       Operator = "in"
+	Contains Operator = "contains"
+)
+
+// AllOperators are all the supported filter operators
+var AllOperators = []Operator{Eq, Ne, Gt, Gte, Lt, Lte, In, Contains}
+
+// Filter keeps the entities whose Field compares to Value with Operator
+// Values holds the comma separated values of In, and Value alone otherwise
+type Filter struct {
+	Field    string
+	Operator Operator
+	Values   []string
+}
+
+// Value returns the first value of the filter
+func (f Filter) Value() string {
+	if len(f.Values) == 0 {
+		return ""
+	}
+	return f.Values[0]
+}
+
+// Sort orders a list on Field
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Query is the structured form of the ListModifiers of a list
+type Query struct {
+	Filters []Filter
+	Sort    []Sort
+	// Limit is the maximum number of entities, 0 when unset
+	Limit int
+	// Offset is the number of entities to skip
+	Offset int
+	// Cursor is where to resume the list from, empty when unset
+	Cursor string
+	// Fields are the only fields the client wants, all of them when empty
+	Fields []string
+}
+
+// QuerySpec is what ParseQuery accepts
+type QuerySpec struct {
+	// Filterable are the fields that can be filtered on, with their
+	// operators, an empty list of operators meaning AllOperators
+	Filterable map[string][]Operator
+	// Sortable are the fields that can be sorted on
+	Sortable []string
+	// MaxLimit is the maximum limit, 0 for no maximum
+	MaxLimit int
+}
+
+// QueryError is returned by ParseQuery for an invalid list modifier
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid list modifier %q: %s", e.Param, e.Reason)
+}
+
+// ParseQuery strictly parses lm according to spec,
+// any list modifier spec doesn't allow is an error
+//
+//	?status_id=1&created_at[gte]=2020-01-01&tag[in]=a,b
+//	&orderby=created_at DESC,id&limit=20&offset=40
+func ParseQuery(lm ListModifiers, spec QuerySpec) (*Query, error) {
+	q := &Query{}
+
+	params := make([]string, 0, len(lm))
+	for param := range lm {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		values := lm[param]
+		var err error
+		switch param {
+		case ParamOrderBy:
+			q.Sort, err = parseSort(values, spec.Sortable)
+		case ParamLimit:
+			// a limit of 0 would be no limit
+			q.Limit, err = parseCount(param, values, 1)
+			if err == nil && spec.MaxLimit > 0 && q.Limit > spec.MaxLimit {
+				err = &QueryError{
+					Param:  param,
+					Reason: fmt.Sprintf("maximum is %d", spec.MaxLimit),
+				}
+			}
+		case ParamOffset:
+			q.Offset, err = parseCount(param, values, 0)
+		case ParamPageToken:
+			q.Cursor, err = single(param, values)
+		case ParamCount:
+		case ParamFields:
+			q.Fields, err = ParseFields(values)
+		default:
+			var fs []Filter
+			fs, err = parseFilters(param, values, spec.Filterable)
+			q.Filters = append(q.Filters, fs...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+func single(param string, values []string) (string, error) {
+	if len(values) != 1 {
+		return "", &QueryError{Param: param, Reason: "must be given once"}
+	}
+	return values[0], nil
+}
+
+// parseCount parses a count of at least min, 0 or 1
+func parseCount(param string, values []string, min int) (int, error) {
+	v, err := single(param, values)
+	if err != nil {
+		return 0, err
+	}
+	n, errConv := strconv.Atoi(v)
+	if errConv != nil || n < min {
+		reason := "must be a positive integer"
+		if min == 0 {
+			reason = "must be a non-negative integer"
+		}
+		return 0, &QueryError{Param: param, Reason: reason}
+	}
+	return n, nil
+}
+
+func parseSort(values []string, sortable []string) ([]Sort, error) {
+	var ss []Sort
+	seen := make(map[string]bool)
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			f := strings.Fields(s)
+			if len(f) == 0 || len(f) > 2 {
+				return nil, &QueryError{
+					Param:  ParamOrderBy,
+					Reason: fmt.Sprintf("%q is not field [ASC|DESC]", s),
+				}
+			}
+
+			srt := Sort{Field: f[0]}
+			if len(f) == 2 {
+				switch strings.ToUpper(f[1]) {
+				case "ASC":
+				case "DESC":
+					srt.Desc = true
+				default:
+					return nil, &QueryError{
+						Param:  ParamOrderBy,
+						Reason: fmt.Sprintf("unknown direction %q", f[1]),
+					}
+				}
+			}
+			if !contains(sortable, srt.Field) {
+				return nil, &QueryError{
+					Param:  ParamOrderBy,
+					Reason: fmt.Sprintf("cannot sort on %q", srt.Field),
+				}
+			}
+			if seen[srt.Field] {
+				return nil, &QueryError{
+					Param:  ParamOrderBy,
+					Reason: fmt.Sprintf("%q is sorted on twice", srt.Field),
+				}
+			}
+			seen[srt.Field] = true
+			ss = append(ss, srt)
+		}
+	}
+	return ss, nil
+}
+
+func parseFilters(
+	param string,
+	values []string,
+	filterable map[string][]Operator,
+) ([]Filter, error) {
+	field, op := param, Eq
+	if i := strings.IndexByte(param, '['); i > 0 && strings.HasSuffix(param, "]") {
+		field, op = param[:i], Operator(param[i+1:len(param)-1])
+	}
+
+	ops, ok := filterable[field]
+	if !ok {
+		return nil, &QueryError{Param: param, Reason: "unknown parameter"}
+	}
+	if len(ops) == 0 {
+		ops = AllOperators
+	}
+	if !containsOperator(ops, op) {
+		return nil, &QueryError{
+			Param:  param,
+			Reason: fmt.Sprintf("operator %q not allowed", op),
+		}
+	}
+
+	fs := make([]Filter, 0, len(values))
+	for _, v := range values {
+		f := Filter{Field: field, Operator: op, Values: []string{v}}
+		if op == In {
+			f.Values = strings.Split(v, ",")
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOperator(list []Operator, op Operator) bool {
+	for _, l := range list {
+		if l == op {
+			return true
+		}
+	}
+	return false
+}
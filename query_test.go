@@ -0,0 +1,138 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	spec := QuerySpec{
+		Filterable: map[string][]Operator{
+			"status_id":  nil,
+			"name":       {Eq, Contains},
+			"created_at": {Gte, Lt},
+		},
+		Sortable: []string{"created_at", "id"},
+		MaxLimit: 100,
+	}
+
+	tests := []struct {
+		name       string
+		lm         ListModifiers
+		want       *Query
+		wantedErrP string
+	}{
+		{
+			name: "empty",
+			lm:   ListModifiers{},
+			want: &Query{},
+		},
+		{
+			name: "filters",
+			lm: ListModifiers{
+				"status_id[in]":   {"1,2"},
+				"name":            {"pol"},
+				"created_at[gte]": {"2020-01-01"},
+			},
+			want: &Query{
+				Filters: []Filter{
+					{Field: "created_at", Operator: Gte,
+						Values: []string{"2020-01-01"}},
+					{Field: "name", Operator: Eq, Values: []string{"pol"}},
+					{Field: "status_id", Operator: In,
+						Values: []string{"1", "2"}},
+				},
+			},
+		},
+		{
+			name: "sort, limit, offset and cursor",
+			lm: ListModifiers{
+				"orderby":    {"created_at DESC,id"},
+				"limit":      {"20"},
+				"offset":     {"40"},
+				"page_token": {"abc"},
+			},
+			want: &Query{
+				Sort: []Sort{
+					{Field: "created_at", Desc: true},
+					{Field: "id"},
+				},
+				Limit:  20,
+				Offset: 40,
+				Cursor: "abc",
+			},
+		},
+		{
+			name:       "unknown field",
+			lm:         ListModifiers{"pol": {"lux"}},
+			wantedErrP: "pol",
+		},
+		{
+			name:       "operator not allowed",
+			lm:         ListModifiers{"name[gt]": {"a"}},
+			wantedErrP: "name[gt]",
+		},
+		{
+			name:       "not sortable",
+			lm:         ListModifiers{"orderby": {"name"}},
+			wantedErrP: "orderby",
+		},
+		{
+			name:       "sorted twice",
+			lm:         ListModifiers{"orderby": {"id", "id DESC"}},
+			wantedErrP: "orderby",
+		},
+		{
+			name:       "bad direction",
+			lm:         ListModifiers{"orderby": {"id UP"}},
+			wantedErrP: "orderby",
+		},
+		{
+			name:       "limit too big",
+			lm:         ListModifiers{"limit": {"101"}},
+			wantedErrP: "limit",
+		},
+		{
+			name:       "negative offset",
+			lm:         ListModifiers{"offset": {"-1"}},
+			wantedErrP: "offset",
+		},
+		{
+			name:       "zero limit",
+			lm:         ListModifiers{"limit": {"0"}},
+			wantedErrP: "limit",
+		},
+		{
+			name: "zero offset",
+			lm:   ListModifiers{"limit": {"1"}, "offset": {"0"}},
+			want: &Query{Limit: 1},
+		},
+		{
+			name:       "limit given twice",
+			lm:         ListModifiers{"limit": {"1", "2"}},
+			wantedErrP: "limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.lm, spec)
+			if tt.wantedErrP != "" {
+				var qe *QueryError
+				if !errors.As(err, &qe) || qe.Param != tt.wantedErrP {
+					t.Errorf("ParseQuery() error = %v, want a QueryError on %q",
+						err, tt.wantedErrP)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ParseQuery() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
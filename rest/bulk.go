@@ -0,0 +1,364 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// DefaultMaxBulkSize is the default maximum number of items
+// of bulk operations
+const DefaultMaxBulkSize = 1000
+
+// BulkItem is the result of an item of a bulk operation,
+// with the status it would have had as a single request
+type BulkItem struct {
+	Index  int           `json:"index"`
+	Status int           `json:"status"`
+	ID     string        `json:"id,omitempty"`
+	Data   interface{}   `json:"data,omitempty"`
+	Error  *crud.Problem `json:"error,omitempty"`
+}
+
+// BulkResponse is the 207 Multi-Status body of bulk operations,
+// its results are in the order of the items of the request
+type BulkResponse struct {
+	Results []BulkItem `json:"results"`
+}
+
+// BulkPatchItem is an item of the body of a bulk PATCH,
+// Patch being a merge patch
+type BulkPatchItem struct {
+	ID    string          `json:"id"`
+	Patch json.RawMessage `json:"patch"`
+}
+
+// errMissingIDs is returned by a bulk DELETE without ids
+var errMissingIDs = &gohttperror.ErrResponse{
+	HTTPStatusCode: http.StatusBadRequest,
+	StatusText:     "Invalid request.",
+	ErrorText:      "missing ids",
+}
+
+// errTooManyItems returns the error of a bulk operation with more than
+// max items
+func errTooManyItems(max int) *gohttperror.ErrResponse {
+	return &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusRequestEntityTooLarge,
+		StatusText:     "Request entity too large",
+		ErrorText:      fmt.Sprintf("more than %d items", max),
+	}
+}
+
+// bulk holds the results of a bulk operation
+type bulk struct {
+	r       *http.Request
+	o       *Options
+	cmgr    interface{}
+	op      Operation
+	results []BulkItem
+}
+
+func newBulk(
+	r *http.Request,
+	o *Options,
+	cmgr interface{},
+	op Operation,
+	n int,
+) *bulk {
+	return &bulk{
+		r: r, o: o, cmgr: cmgr, op: op,
+		results: make([]BulkItem, n),
+	}
+}
+
+// fail sets the error of the item i
+func (b *bulk) fail(i int, id string, e *gohttperror.ErrResponse) {
+	p := NewProblem(b.r, e)
+	b.results[i] = BulkItem{Index: i, Status: p.Status, ID: id, Error: p}
+}
+
+// set sets the result of the item i
+func (b *bulk) set(i int, id string, res crud.BulkResult) {
+	if res.Err != nil {
+		b.fail(i, id, mapError(b.cmgr, res.Err))
+		return
+	}
+
+	item := BulkItem{Index: i, Status: b.o.Statuses[b.op], ID: id}
+	if eid, ok := crud.EntityID(res.Entity); ok {
+		item.ID = eid
+	}
+	switch {
+	case item.Status != http.StatusOK && item.Status != http.StatusCreated:
+	case res.Entity != nil:
+		item.Data = res.Entity
+	case item.Status == http.StatusOK:
+		item.Status = http.StatusNoContent
+	}
+	b.results[i] = item
+}
+
+// setAll sets the results of the items idx, returned by a bulk manager
+func (b *bulk) setAll(idx []int, ids []string, res []crud.BulkResult) {
+	for j, i := range idx {
+		if j >= len(res) {
+			b.fail(i, ids[j], gohttperror.ErrInternal(
+				fmt.Errorf("%d results for %d items", len(res), len(idx)),
+			))
+			continue
+		}
+		b.set(i, ids[j], res[j])
+	}
+}
+
+func (b *bulk) respond(w http.ResponseWriter) error {
+	return respond(w, http.StatusMultiStatus,
+		&BulkResponse{Results: b.results},
+	)
+}
+
+// BulkPOSTHandler creates the entities of a JSON array,
+// cmgr is a crud.BulkCreator or a crud.Creator
+func BulkPOSTHandler(
+	cmgr crud.EntityFactory,
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	// the schema validates the items, not the array
+	d := o.decodeOptions(OpBulkCreate)
+	item := d
+	item.schema = o.entitySchema(cmgr)
+
+	return o.idempotent("BulkPOSTHandler", OpBulkCreate, func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, "BulkPOSTHandler", errRender)
+			}
+		}()
+
+		var raws []json.RawMessage
+		if _, errD := decodeBody(w, r, d, &raws); errD != nil {
+			errRender = renderError(w, r, o, errD)
+			return
+		}
+		if len(raws) > o.MaxBulkSize {
+			errRender = renderError(w, r, o, errTooManyItems(o.MaxBulkSize))
+			return
+		}
+
+		b := newBulk(r, o, cmgr, OpCreate, len(raws))
+		var idx []int
+		var ents []interface{}
+		for i, raw := range raws {
+			ent := cmgr.NewEmptyEntity()
+			if err := decodeJSON(raw, ent, item); err != nil {
+				b.fail(i, "", decodeError(err))
+				continue
+			}
+			if err := crud.Validate(ent); err != nil {
+				b.fail(i, "", mapError(cmgr, err))
+				continue
+			}
+			idx = append(idx, i)
+			ents = append(ents, ent)
+		}
+
+		ctx := r.Context()
+		if bc, ok := cmgr.(crud.BulkCreator); ok {
+			if len(ents) > 0 {
+				ids := make([]string, len(idx))
+				b.setAll(idx, ids, bc.CreateMany(ctx, ents))
+			}
+		} else {
+			c := cmgr.(crud.Creator)
+			for j, i := range idx {
+				e, err := c.Create(ctx, ents[j], bytes.NewReader(raws[i]))
+				b.set(i, "", crud.BulkResult{Entity: e, Err: err})
+			}
+		}
+
+		errRender = b.respond(w)
+	})
+}
+
+// BulkPATCHHandler applies the merge patches of a JSON array of
+// BulkPatchItem, cmgr is a crud.BulkPatcher, a crud.Patcher,
+// or a crud.Getter and crud.Updater
+// With WithRequiredPreconditions, it answers 428 Precondition Required
+func BulkPATCHHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, "BulkPATCHHandler", errRender)
+			}
+		}()
+		// the items can't have preconditions
+		if o.RequirePreconditions {
+			errRender = renderError(w, r, o, errBulkPreconditionRequired)
+			return
+		}
+
+		d := o.decodeOptions(OpBulkPatch)
+		var items []BulkPatchItem
+		if _, errD := decodeBody(w, r, d, &items); errD != nil {
+			errRender = renderError(w, r, o, errD)
+			return
+		}
+		if len(items) > o.MaxBulkSize {
+			errRender = renderError(w, r, o, errTooManyItems(o.MaxBulkSize))
+			return
+		}
+
+		ctx := r.Context()
+		b := newBulk(r, o, cmgr, OpPatch, len(items))
+		bp, isBulk := cmgr.(crud.BulkPatcher)
+		var idx []int
+		var ids []string
+		var patches []crud.BulkPatch
+		for i, item := range items {
+			ID, err := crud.ParseID(cmgr, item.ID)
+			if err != nil {
+				b.fail(i, item.ID, gohttperror.ErrBadRequest(err))
+				continue
+			}
+			updates, err := decodeMergePatch(cmgr, item.Patch, d)
+			if err != nil {
+				b.fail(i, item.ID, mapError(cmgr, err))
+				continue
+			}
+			if !isBulk {
+				e, err := mergePatch(ctx, cmgr, ID, updates, item.Patch)
+				b.set(i, item.ID, crud.BulkResult{Entity: e, Err: err})
+				continue
+			}
+			if err := validateMergePatch(ctx, cmgr, ID, updates); err != nil {
+				b.fail(i, item.ID, mapError(cmgr, err))
+				continue
+			}
+			idx = append(idx, i)
+			ids = append(ids, item.ID)
+			patches = append(patches, crud.BulkPatch{ID: ID, Patch: updates})
+		}
+		if len(patches) > 0 {
+			b.setAll(idx, ids, bp.PatchMany(ctx, patches))
+		}
+
+		errRender = b.respond(w)
+	}
+}
+
+// BulkDELETEHandler deletes the entities of the ids query parameter,
+// comma separated or repeated, cmgr is a crud.BulkDeleter or a
+// crud.Deleter
+// With WithRequiredPreconditions, it answers 428 Precondition Required
+func BulkDELETEHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, "BulkDELETEHandler", errRender)
+			}
+		}()
+		// the items can't have preconditions
+		if o.RequirePreconditions {
+			errRender = renderError(w, r, o, errBulkPreconditionRequired)
+			return
+		}
+
+		var raws []string
+		for _, v := range r.URL.Query()["ids"] {
+			for _, id := range strings.Split(v, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					raws = append(raws, id)
+				}
+			}
+		}
+		if len(raws) == 0 {
+			errRender = renderError(w, r, o, errMissingIDs)
+			return
+		}
+		if len(raws) > o.MaxBulkSize {
+			errRender = renderError(w, r, o, errTooManyItems(o.MaxBulkSize))
+			return
+		}
+
+		ctx := r.Context()
+		b := newBulk(r, o, cmgr, OpDelete, len(raws))
+		var idx []int
+		var ids []string
+		var parsed []crud.ID
+		for i, raw := range raws {
+			ID, err := crud.ParseID(cmgr, raw)
+			if err != nil {
+				b.fail(i, raw, gohttperror.ErrBadRequest(err))
+				continue
+			}
+			idx = append(idx, i)
+			ids = append(ids, raw)
+			parsed = append(parsed, ID)
+		}
+
+		if bd, ok := cmgr.(crud.BulkDeleter); ok {
+			if len(parsed) > 0 {
+				b.setAll(idx, ids, bd.DeleteMany(ctx, parsed))
+			}
+		} else {
+			del := cmgr.(crud.Deleter)
+			for j, i := range idx {
+				err := del.Delete(ctx, parsed[j])
+				b.set(i, ids[j], crud.BulkResult{Err: err})
+			}
+		}
+
+		errRender = b.respond(w)
+	}
+}
+
+// isArrayBody returns true if the body of r is a JSON array,
+// without consuming it
+// Only a bounded prefix is peeked, the handler reading the body then
+// applies its size limit to all of it, leading whitespace included
+func isArrayBody(r *http.Request) bool {
+	br := bufio.NewReader(r.Body)
+	r.Body = readCloser{Reader: br, Closer: r.Body}
+	for n := 1; n <= br.Size(); n++ {
+		b, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b[n-1] == '['
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
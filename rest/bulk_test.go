@@ -0,0 +1,240 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+// bulkMgr is a mock manager running batches itself
+type bulkMgr struct {
+	*mock.Mgr
+	batches int
+}
+
+func (m *bulkMgr) CreateMany(
+	ctx context.Context,
+	es []interface{},
+) []crud.BulkResult {
+	m.batches++
+	res := make([]crud.BulkResult, len(es))
+	for i, e := range es {
+		res[i].Entity, res[i].Err = m.Create(ctx, e, nil)
+	}
+	return res
+}
+
+func (m *bulkMgr) PatchMany(
+	ctx context.Context,
+	ps []crud.BulkPatch,
+) []crud.BulkResult {
+	m.batches++
+	res := make([]crud.BulkResult, len(ps))
+	for i, p := range ps {
+		res[i].Err = m.PartialUpdate(ctx, p.ID, p.Patch, nil)
+	}
+	return res
+}
+
+func (m *bulkMgr) DeleteMany(
+	ctx context.Context,
+	ids []crud.ID,
+) []crud.BulkResult {
+	m.batches++
+	// a faulty manager, missing the last result
+	res := make([]crud.BulkResult, len(ids)-1)
+	for i := range res {
+		res[i].Err = m.Delete(ctx, ids[i])
+	}
+	return res
+}
+
+func TestBulkHandlers(t *testing.T) {
+	missing := xid.New().String()
+
+	tests := []struct {
+		name           string
+		method         string
+		bulk           bool
+		opts           []Option
+		path           string
+		body           string
+		wantedStatus   int
+		wantedStatuses []int
+		wantedStatusID int
+	}{
+		{
+			name:           "create",
+			method:         "POST",
+			body:           `[{"status_id": 2}, {"status_id": "2"}, {}]`,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{201, 400, 201},
+			wantedStatusID: 1,
+		},
+		{
+			name:           "create in a batch",
+			method:         "POST",
+			bulk:           true,
+			body:           `[{"status_id": 2}, {"status_id": "2"}, {}]`,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{201, 400, 201},
+			wantedStatusID: 1,
+		},
+		{
+			name:         "create an object",
+			method:       "POST",
+			body:         `{"status_id": 2}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "create too many",
+			method:       "POST",
+			opts:         []Option{WithMaxBulkSize(2)},
+			body:         `[{}, {}, {}]`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "patch",
+			method: "PATCH",
+			body: `[{"id": "{id}", "patch": {"status_id": 3}},
+				{"id": "bad", "patch": {"status_id": 3}},
+				{"id": "` + missing + `", "patch": {"status_id": 3}},
+				{"id": "{id}", "patch": {"status": 3}}]`,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{204, 400, 404, 400},
+			wantedStatusID: 3,
+		},
+		{
+			name:   "patch in a batch",
+			method: "PATCH",
+			bulk:   true,
+			body: `[{"id": "{id}", "patch": {"status_id": 3}},
+				{"id": "bad", "patch": {"status_id": 3}},
+				{"id": "` + missing + `", "patch": {"status_id": 3}}]`,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{204, 400, 404},
+			wantedStatusID: 3,
+		},
+		{
+			name:           "patch with 200",
+			method:         "PATCH",
+			opts:           []Option{WithStatus(OpPatch, http.StatusOK)},
+			body:           `[{"id": "{id}", "patch": {"status_id": 3}}]`,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{204},
+			wantedStatusID: 3,
+		},
+		{
+			name:           "delete",
+			method:         "DELETE",
+			path:           "?ids={id},bad&ids=" + missing,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{202, 400, 404},
+		},
+		{
+			name:           "delete in a faulty batch",
+			method:         "DELETE",
+			bulk:           true,
+			path:           "?ids={id}," + missing,
+			wantedStatus:   http.StatusMultiStatus,
+			wantedStatuses: []int{202, 500},
+		},
+		{
+			name:         "patch with required preconditions",
+			method:       "PATCH",
+			opts:         []Option{WithRequiredPreconditions()},
+			body:         `[{"id": "{id}", "patch": {"status_id": 3}}]`,
+			wantedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "delete with required preconditions",
+			method:       "DELETE",
+			opts:         []Option{WithRequiredPreconditions()},
+			path:         "?ids={id}",
+			wantedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "delete without ids",
+			method:       "DELETE",
+			path:         "?ids=",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &bulkMgr{Mgr: mock.NewMgr()}
+			ec, _ := m.Create(
+				context.Background(), &mock.Entity{StatusID: 1}, nil,
+			)
+			id := ec.(*mock.Entity).ID
+			replaceID := func(s string) string {
+				return strings.ReplaceAll(s, "{id}", id.String())
+			}
+
+			var cmgr crud.MgrI = m.Mgr
+			if tt.bulk {
+				cmgr = m
+			}
+			h := map[string]func(http.ResponseWriter, *http.Request){
+				"POST":   BulkPOSTHandler(cmgr, tt.opts...),
+				"PATCH":  BulkPATCHHandler(cmgr, tt.opts...),
+				"DELETE": BulkDELETEHandler(cmgr, tt.opts...),
+			}[tt.method]
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/e"+replaceID(tt.path),
+				bytes.NewBufferString(replaceID(tt.body)))
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if rr.Code != http.StatusMultiStatus {
+				return
+			}
+			if tt.bulk && m.batches != 1 {
+				t.Errorf("manager ran %d batches", m.batches)
+			}
+
+			var resp BulkResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Results) != len(tt.wantedStatuses) {
+				t.Fatalf("handler returned %d results, want %d",
+					len(resp.Results), len(tt.wantedStatuses))
+			}
+			for i, res := range resp.Results {
+				if res.Index != i || res.Status != tt.wantedStatuses[i] {
+					t.Errorf("result %d: got %d, status %d, want status %d",
+						i, res.Index, res.Status, tt.wantedStatuses[i])
+				}
+				if (res.Error != nil) != (res.Status >= 400) {
+					t.Errorf("result %d: error %v", i, res.Error)
+				}
+				if (res.Data != nil) != (res.Status == 201) {
+					t.Errorf("result %d: data %v", i, res.Data)
+				}
+				if res.Status == 201 && res.ID == "" {
+					t.Errorf("result %d: no id", i)
+				}
+			}
+			if tt.wantedStatusID != 0 &&
+				m.EntityList[id].StatusID != tt.wantedStatusID {
+				t.Errorf("entity has status %d, want %d",
+					m.EntityList[id].StatusID, tt.wantedStatusID)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// DefaultMaxBodyBytes is the default maximum size of request bodies
+const DefaultMaxBodyBytes = 1 << 20
+
+// DecodeOptions are the settings of the decoding of request bodies
+type DecodeOptions struct {
+	// MaxBytes is the maximum size of a body, bigger ones are answered
+	// with 413 Request Entity Too Large
+	// 0 means DefaultMaxBodyBytes, a negative value no limit
+	MaxBytes int64
+	// DisallowUnknownFields rejects objects with fields
+	// the entity does not have
+	DisallowUnknownFields bool
+	// DisallowTrailingData rejects bodies with data after the JSON value
+	DisallowTrailingData bool
+	// RequireJSON answers 415 Unsupported Media Type to bodies
+	// not typed application/json, or a +json type
+	RequireJSON bool
+
+	// schema validates the bodies before they are decoded, if set
+	schema *crud.Schema
+}
+
+var errUnsupportedMediaType = &gohttperror.ErrResponse{
+	HTTPStatusCode: http.StatusUnsupportedMediaType,
+	StatusText:     "Unsupported media type",
+	ErrorText:      "Content-Type must be application/json",
+}
+
+// errTooLarge returns the error of a body bigger than its limit
+func errTooLarge(err error) *gohttperror.ErrResponse {
+	return &gohttperror.ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusRequestEntityTooLarge,
+		StatusText:     "Request entity too large",
+	}
+}
+
+// isJSON returns true if the request body is typed as JSON
+func isJSON(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil &&
+		(mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// readBody reads the body of r, up to the MaxBytes of d
+func readBody(
+	w http.ResponseWriter,
+	r *http.Request,
+	d DecodeOptions,
+) ([]byte, *gohttperror.ErrResponse) {
+	body := r.Body
+	switch {
+	case d.MaxBytes == 0:
+		body = http.MaxBytesReader(w, r.Body, DefaultMaxBodyBytes)
+	case d.MaxBytes > 0:
+		body = http.MaxBytesReader(w, r.Body, d.MaxBytes)
+	}
+	b, err := io.ReadAll(body)
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe):
+		return nil, errTooLarge(err)
+	case err != nil:
+		return nil, gohttperror.ErrBadRequest(err)
+	}
+	return b, nil
+}
+
+// decodeJSON decodes the JSON value of b into v following d
+// The body is validated against the schema of d first, if any
+func decodeJSON(b []byte, v interface{}, d DecodeOptions) error {
+	if d.schema != nil {
+		if err := d.schema.ValidateJSON(b); err != nil {
+			return err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if d.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if d.DisallowTrailingData {
+		if _, err := dec.Token(); err != io.EOF {
+			return fmt.Errorf("unexpected data after the JSON value")
+		}
+	}
+	return nil
+}
+
+// decodeBody reads the JSON body of r into v following d,
+// and returns the body
+func decodeBody(
+	w http.ResponseWriter,
+	r *http.Request,
+	d DecodeOptions,
+	v interface{},
+) ([]byte, *gohttperror.ErrResponse) {
+	if d.RequireJSON && !isJSON(r) {
+		return nil, errUnsupportedMediaType
+	}
+	b, e := readBody(w, r, d)
+	if e != nil {
+		return nil, e
+	}
+	if err := decodeJSON(b, v, d); err != nil {
+		return nil, decodeError(err)
+	}
+	return b, nil
+}
+
+// decodeError returns the error response of a body that cannot be decoded,
+// 422 Unprocessable Entity if it is not valid against the schema
+func decodeError(err error) *gohttperror.ErrResponse {
+	if errors.Is(err, crud.ErrUnprocessableEntity) {
+		return mapError(nil, err)
+	}
+	// the errors of encoding/json name the Go types
+	e := gohttperror.ErrBadRequest(err)
+	e.ErrorText = "invalid JSON body"
+	return e
+}
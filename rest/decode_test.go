@@ -0,0 +1,215 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/induzo/crud/mock"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	strict := DecodeOptions{
+		MaxBytes:              32,
+		DisallowUnknownFields: true,
+		DisallowTrailingData:  true,
+		RequireJSON:           true,
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		opts         []Option
+		contentType  string
+		body         string
+		wantedStatus int
+	}{
+		{
+			name:         "lenient by default",
+			method:       "POST",
+			body:         `{"status_id": 1, "x": 1} trailing`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:   "default size limit",
+			method: "POST",
+			body: `{"status_id": 1, "x": "` +
+				strings.Repeat("x", DefaultMaxBodyBytes) + `"}`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "no size limit",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(DecodeOptions{MaxBytes: -1})},
+			body:         `{"x": "` + strings.Repeat("x", DefaultMaxBodyBytes) + `"}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "strict",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  "application/json; charset=utf-8",
+			body:         `{"status_id": 1}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "too large",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  "application/json",
+			body:         `{"status_id": 1, "padding": "xxxxxxxxxxxxxxxxxxxxxxxxx"}`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "unknown field",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  "application/json",
+			body:         `{"status": 1}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "trailing data",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  "application/json",
+			body:         `{"status_id": 1} {}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "not JSON",
+			method:       "POST",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  "text/plain",
+			body:         `{"status_id": 1}`,
+			wantedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "strict PUT only",
+			method: "POST",
+			opts: []Option{
+				WithDecodeOptions(strict, OpReplace|OpPatch),
+			},
+			body:         `{"status_id": 1, "status": 1}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:   "strict PUT",
+			method: "PUT",
+			opts: []Option{
+				WithDecodeOptions(strict, OpReplace|OpPatch),
+			},
+			contentType:  "application/json",
+			body:         `{"status_id": 1, "status": 1}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "lenient PUT",
+			method: "PUT",
+			opts: []Option{
+				WithDecodeOptions(strict),
+				WithDecodeOptions(DecodeOptions{}, OpReplace),
+			},
+			body:         `{"status_id": 1, "status": 1}`,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "PATCH too large",
+			method:       "PATCH",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  MergePatchContentType,
+			body:         `{"status_id": 1, "padding": "xxxxxxxxxxxxxxxxxxxxxxxxx"}`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "PATCH trailing data",
+			method:       "PATCH",
+			opts:         []Option{WithDecodeOptions(strict)},
+			contentType:  MergePatchContentType,
+			body:         `{"status_id": 1} {}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			ec, _ := m.Create(context.Background(), &mock.Entity{}, nil)
+			id := ec.(*mock.Entity).ID
+
+			h := map[string]func(http.ResponseWriter, *http.Request){
+				"POST":  POSTHandler(m, tt.opts...),
+				"PUT":   PUTHandler(m, tt.opts...),
+				"PATCH": PATCHHandler(m, tt.opts...),
+			}[tt.method]
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/entity",
+				bytes.NewBufferString(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req = req.WithContext(GetTestContextWithID(req.Context(), id))
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+		})
+	}
+}
+
+func TestResourceBodyLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		body         string
+		wantedStatus int
+	}{
+		{
+			name: "leading whitespace",
+			body: strings.Repeat(" ", 2*DefaultMaxBodyBytes) +
+				`{"status_id": 1}`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "single limit",
+			opts: []Option{
+				WithDecodeOptions(DecodeOptions{MaxBytes: 16}, OpCreate),
+			},
+			body:         `    {"status_id": 1}`,
+			wantedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "bulk limit bigger than the single one",
+			opts: []Option{
+				WithDecodeOptions(DecodeOptions{MaxBytes: 16}, OpCreate),
+			},
+			body:         `    [{"status_id": 1}]`,
+			wantedStatus: http.StatusMultiStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithPath("/e")}, tt.opts...)
+			res := NewResource(mock.NewMgr(), opts...)
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/e",
+				bytes.NewBufferString(tt.body))
+
+			res.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// mapError maps err with the crud.ErrorMapper of cmgr,
+// errors of managers without one are internal errors
+// The errors of the crud package always have the same status,
+// and a crud.Problem always has its own
+func mapError(cmgr interface{}, err error) *gohttperror.ErrResponse {
+	var qe *crud.QueryError
+	switch {
+	case errors.As(err, &qe), errors.Is(err, crud.ErrInvalidPatch),
+		errors.Is(err, crud.ErrInvalidID):
+		return gohttperror.ErrBadRequest(err)
+	case errors.Is(err, crud.ErrPatchConflict):
+		return &gohttperror.ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			StatusText:     "Conflict",
+		}
+	case errors.Is(err, crud.ErrUnprocessableEntity):
+		return &gohttperror.ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusUnprocessableEntity,
+			StatusText:     "Unprocessable entity",
+		}
+	}
+	var p *crud.Problem
+	if errors.As(err, &p) {
+		status := p.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return &gohttperror.ErrResponse{
+			Err:            err,
+			HTTPStatusCode: status,
+			StatusText:     p.Title,
+			ErrorText:      p.Detail,
+		}
+	}
+	if em, ok := cmgr.(crud.ErrorMapper); ok {
+		if e := em.MapErrorToHTTPError(err); e != nil {
+			return e
+		}
+	}
+	return gohttperror.ErrInternal(err)
+}
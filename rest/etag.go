@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+var (
+	errPreconditionFailed = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusPreconditionFailed,
+		StatusText:     "Precondition failed",
+	}
+	errPreconditionRequired = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusPreconditionRequired,
+		StatusText:     "Precondition required",
+		ErrorText:      "If-Match is required",
+	}
+	errBulkPreconditionRequired = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusPreconditionRequired,
+		StatusText:     "Precondition required",
+		ErrorText: "If-Match is required, modify the entities " +
+			"one by one on their own path",
+	}
+)
+
+// formatETag returns the strong ETag of a version
+func formatETag(version string) string {
+	return `"` + strings.ReplaceAll(version, `"`, "") + `"`
+}
+
+// setETag sets the ETag header of e if cmgr is a crud.Versioner
+func setETag(w http.ResponseWriter, cmgr interface{}, e interface{}) {
+	if v, ok := cmgr.(crud.Versioner); ok {
+		w.Header().Set("ETag", formatETag(v.Version(e)))
+	}
+}
+
+// etagsMatch returns true if etag is in the list of ETags of a
+// conditional header, with the weak comparison if weak, strong otherwise
+func etagsMatch(header []string, etag string, weak bool) bool {
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	}
+	for _, h := range header {
+		for _, t := range strings.Split(h, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" {
+				return true
+			}
+			if strings.HasPrefix(t, "W/") {
+				if !weak {
+					continue
+				}
+				t = t[2:]
+			}
+			if t == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates the If-Match header of a modification of the
+// entity id against its current version, and returns the context of the
+// modification, carrying the expected version
+func checkIfMatch(
+	r *http.Request,
+	cmgr interface{},
+	o *Options,
+	id crud.ID,
+) (*http.Request, *gohttperror.ErrResponse) {
+	ifMatch := r.Header.Values("If-Match")
+	if len(ifMatch) == 0 {
+		if o.RequirePreconditions {
+			return r, errPreconditionRequired
+		}
+		return r, nil
+	}
+
+	// the current version can't be known, so it can't match
+	g, ok := cmgr.(crud.Getter)
+	if !ok {
+		return r, errPreconditionFailed
+	}
+	e, err := g.Get(r.Context(), id)
+	if err != nil {
+		me := mapError(cmgr, err)
+		if me.HTTPStatusCode == http.StatusNotFound {
+			return r, errPreconditionFailed
+		}
+		return r, me
+	}
+
+	v, ok := cmgr.(crud.Versioner)
+	if !ok {
+		// without versions, the ETag sent by GET is a hash of the entity
+		b, err := encodeJSON(e)
+		if err != nil {
+			return r, gohttperror.ErrInternal(err)
+		}
+		if !etagsMatch(ifMatch, bodyETag(b), false) {
+			return r, errPreconditionFailed
+		}
+		return r, nil
+	}
+	version := v.Version(e)
+	if !etagsMatch(ifMatch, formatETag(version), false) {
+		return r, errPreconditionFailed
+	}
+
+	return r.WithContext(crud.WithIfMatch(r.Context(), version)), nil
+}
@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+)
+
+// versionMgr is a mock manager whose entity versions are their status
+type versionMgr struct {
+	*mock.Mgr
+	ifMatch string
+}
+
+func (m *versionMgr) Version(e interface{}) string {
+	return strconv.Itoa(e.(*mock.Entity).StatusID)
+}
+
+func (m *versionMgr) Delete(ctx context.Context, id crud.ID) error {
+	m.ifMatch, _ = crud.IfMatchFromContext(ctx)
+	return m.Mgr.Delete(ctx, id)
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		ifMatch       string
+		require       bool
+		noVersion     bool
+		noGetter      bool
+		missing       bool
+		wantedStatus  int
+		wantedIfMatch string
+	}{
+		{
+			name:         "GET sends the ETag",
+			method:       "GET",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "PUT without If-Match",
+			method:       "PUT",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "PUT matching",
+			method:       "PUT",
+			ifMatch:      `"1"`,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "PUT not matching",
+			method:       "PUT",
+			ifMatch:      `"0", W/"1"`,
+			wantedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "PATCH matching one of the list",
+			method:       "PATCH",
+			ifMatch:      `"0", "1"`,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "PATCH not matching",
+			method:       "PATCH",
+			ifMatch:      `"2"`,
+			wantedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:          "DELETE matching",
+			method:        "DELETE",
+			ifMatch:       `"1"`,
+			wantedStatus:  http.StatusAccepted,
+			wantedIfMatch: "1",
+		},
+		{
+			name:         "DELETE matching a missing entity",
+			method:       "DELETE",
+			ifMatch:      `*`,
+			missing:      true,
+			wantedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "DELETE without a required If-Match",
+			method:       "DELETE",
+			require:      true,
+			wantedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "DELETE without versions matching any",
+			method:       "DELETE",
+			ifMatch:      `*`,
+			noVersion:    true,
+			wantedStatus: http.StatusAccepted,
+		},
+		{
+			name:         "DELETE without versions",
+			method:       "DELETE",
+			ifMatch:      `"1"`,
+			noVersion:    true,
+			wantedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "DELETE without Get",
+			method:       "DELETE",
+			ifMatch:      `*`,
+			noGetter:     true,
+			wantedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &versionMgr{Mgr: mock.NewMgr()}
+			ec, _ := m.Create(
+				context.Background(), &mock.Entity{StatusID: 1}, nil,
+			)
+			id := ec.(*mock.Entity).ID
+			if tt.missing {
+				_ = m.Mgr.Delete(context.Background(), id)
+			}
+
+			var cmgr crud.MgrI = m
+			if tt.noVersion {
+				cmgr = m.Mgr
+			}
+			var opts []Option
+			if tt.require {
+				opts = append(opts, WithRequiredPreconditions())
+			}
+			h := map[string]func(http.ResponseWriter, *http.Request){
+				"GET":    GETHandler(cmgr, opts...),
+				"PUT":    PUTHandler(cmgr, opts...),
+				"PATCH":  PATCHHandler(cmgr, opts...),
+				"DELETE": DELETEHandler(cmgr, opts...),
+			}[tt.method]
+			if tt.noGetter {
+				h = DELETEHandler(deleteOnlyMgr{m.Mgr}, opts...)
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/entity",
+				bytes.NewBufferString(`{"status_id": 2}`))
+			if tt.method == "PATCH" {
+				req.Header.Set("Content-Type", MergePatchContentType)
+			}
+			req = req.WithContext(GetTestContextWithID(req.Context(), id))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			wantedETag := map[string]string{"GET": `"1"`, "PUT": `"2"`}
+			if rr.Code < 300 && rr.Header().Get("ETag") != wantedETag[tt.method] {
+				t.Errorf("handler returned ETag %q, want %q",
+					rr.Header().Get("ETag"), wantedETag[tt.method])
+			}
+			if m.ifMatch != tt.wantedIfMatch {
+				t.Errorf("manager got If-Match version %q, want %q",
+					m.ifMatch, tt.wantedIfMatch)
+			}
+		})
+	}
+}
+
+func TestIfMatchBodyETag(t *testing.T) {
+	m := mock.NewMgr()
+	ec, _ := m.Create(context.Background(), &mock.Entity{StatusID: 1}, nil)
+	id := ec.(*mock.Entity).ID
+
+	do := func(h http.HandlerFunc, method, ifMatch string) *http.Response {
+		req := httptest.NewRequest(method, "/entity",
+			bytes.NewBufferString(`{"status_id": 2}`))
+		req = req.WithContext(GetTestContextWithID(req.Context(), id))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr.Result()
+	}
+
+	etag := do(GETHandler(m), "GET", "").Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("GET didn't send an ETag")
+	}
+	if res := do(PUTHandler(m), "PUT", etag); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT with the ETag of GET returned %d", res.StatusCode)
+	}
+	// the entity changed
+	if res := do(PUTHandler(m), "PUT", etag); res.StatusCode !=
+		http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale ETag returned %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,25 @@
+package rest
+
+import (
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// project checks the requested fields against the type of v,
+// and reduces v to them
+func project(
+	v interface{},
+	fields []string,
+) (interface{}, *gohttperror.ErrResponse) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+	if err := crud.CheckFields(v, fields); err != nil {
+		return nil, gohttperror.ErrBadRequest(err)
+	}
+	pv, err := crud.Project(v, fields)
+	if err != nil {
+		return nil, gohttperror.ErrInternal(err)
+	}
+	return pv, nil
+}
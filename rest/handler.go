@@ -2,168 +2,277 @@ package rest
 
 import (
 	"bytes"
-	"encoding/json"
-	"io"
-	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/induzo/crud"
 
-	"github.com/go-chi/render"
 	"github.com/induzo/gohttperror"
 )
 
 // POSTHandler will handle data from request
 // and returns bytes to be written to response
+// Retries with the same Idempotency-Key get the first response,
+// see WithIdempotencyStore
 func POSTHandler(
-	cmgr crud.MgrI,
+	cmgr crud.Creator,
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	d := o.decodeOptions(OpCreate)
+	d.schema = o.entitySchema(cmgr)
+
+	return o.idempotent("POSTHandler", OpCreate, func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("POSTHandler Render: %v", errRender)
+				o.renderFailed(r, "POSTHandler", errRender)
 			}
 		}()
 
-		var payload bytes.Buffer
-		pl := io.TeeReader(r.Body, &payload)
-
 		ent := cmgr.NewEmptyEntity()
-		if errJSON := json.NewDecoder(pl).Decode(ent); errJSON != nil {
-			errRender = render.Render(w, r,
-				gohttperror.ErrBadRequest(errJSON),
-			)
+		payload, errD := decodeBody(w, r, d, ent)
+		if errD != nil {
+			errRender = renderError(w, r, o, errD)
+			return
+		}
+		if errV := crud.Validate(ent); errV != nil {
+			errRender = renderError(w, r, o, mapError(cmgr, errV))
 			return
 		}
 
-		e, err := cmgr.Create(r.Context(), ent, &payload)
+		e, err := cmgr.Create(r.Context(), ent, bytes.NewReader(payload))
 		if err != nil {
-			errRender = render.Render(w, r, gohttperror.ErrInternal(err))
+			errRender = renderError(w, r, o, mapError(cmgr, err))
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		render.DefaultResponder(w, r, e)
-	}
+		setLocation(w, r, e)
+		status, body := o.success(w, r, OpCreate, true)
+		if !body {
+			w.WriteHeader(status)
+			return
+		}
+		errRender = respond(w, status, e)
+	})
 }
 
 // GETListHandler will handle data from request
 // and returns bytes to be written to response
 func GETListHandler(
-	cmgr crud.MgrI,
+	cmgr crud.Lister,
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("GETListHandler Render: %v", errRender)
+				o.renderFailed(r, "GETListHandler", errRender)
 			}
 		}()
 
-		es, errGL := cmgr.GetList(
-			r.Context(),
-			ListModifiersFromURL(r.URL),
-		)
+		lm := ListModifiersFromURL(r.URL)
+		if errP := paginate(lm, o); errP != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errP))
+			return
+		}
+		count, errC := wantCount(lm)
+		if errC != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errC))
+			return
+		}
+		fields, errF := crud.ParseFields(lm[crud.ParamFields])
+		if errF != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errF))
+			return
+		}
+
+		es, errGL := cmgr.GetList(r.Context(), lm)
 		if errGL != nil {
-			errRender = render.Render(w, r, cmgr.MapErrorToHTTPError(errGL))
+			errRender = renderError(w, r, o, mapError(cmgr, errGL))
 			return
 		}
 
-		render.DefaultResponder(w, r, es)
+		var meta ListMeta
+		if counter, ok := cmgr.(crud.Counter); ok && count {
+			total, errCount := counter.Count(r.Context(), lm)
+			if errCount != nil {
+				errRender = renderError(w, r, o, mapError(cmgr, errCount))
+				return
+			}
+			meta.Total = &total
+			w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+		}
+		meta.Limit, meta.Offset = offsetMeta(lm)
+		if p, ok := es.(*crud.Page); ok {
+			setPageLinks(w, r, p, o, &meta)
+			es = p.Items
+		}
+		lastMod := lastModified(cmgr, es, true)
+		es, errPr := project(es, fields)
+		if errPr != nil {
+			errRender = renderError(w, r, o, errPr)
+			return
+		}
+
+		var body interface{} = es
+		if o.ListEnvelope {
+			body = &ListEnvelope{Data: es, Meta: meta}
+		}
+		errRender = respondConditional(w, r, body, "", lastMod)
 	}
 }
 
 // GETHandler returns a unique entity
 func GETHandler(
-	cmgr crud.MgrI,
+	cmgr crud.Getter,
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("GETHandler Render: %v", errRender)
+				o.renderFailed(r, "GETHandler", errRender)
 			}
 		}()
 
-		ID, errParse := parseIDFromRequest(r)
+		ID, errParse := parseIDFromRequest(r, cmgr, o.IDParam)
 		if errParse != nil {
-			errRender = render.Render(w, r,
+			errRender = renderError(w, r, o,
 				gohttperror.ErrBadRequest(errParse),
 			)
 			return
 		}
 
+		fields, errF := crud.ParseFields(r.URL.Query()[crud.ParamFields])
+		if errF != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errF))
+			return
+		}
+
 		e, errG := cmgr.Get(r.Context(), ID)
 		if errG != nil {
-			errRender = render.Render(w, r, cmgr.MapErrorToHTTPError(errG))
+			errRender = renderError(w, r, o, mapError(cmgr, errG))
+			return
+		}
+		// the version only identifies the full representation
+		var etag string
+		if v, ok := cmgr.(crud.Versioner); ok && len(fields) == 0 {
+			etag = formatETag(v.Version(e))
+		}
+		lastMod := lastModified(cmgr, e, false)
+		e, errPr := project(e, fields)
+		if errPr != nil {
+			errRender = renderError(w, r, o, errPr)
 			return
 		}
 
-		render.DefaultResponder(w, r, e)
+		errRender = respondConditional(w, r, e, etag, lastMod)
 	}
 }
 
 // DELETEHandler will delete a specific entity
 func DELETEHandler(
-	cmgr crud.MgrI,
+	cmgr crud.Deleter,
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("DELETEHandler Render: %v", errRender)
+				o.renderFailed(r, "DELETEHandler", errRender)
 			}
 		}()
 
-		ID, errParse := parseIDFromRequest(r)
+		ID, errParse := parseIDFromRequest(r, cmgr, o.IDParam)
 		if errParse != nil {
-			errRender = render.Render(w, r,
+			errRender = renderError(w, r, o,
 				gohttperror.ErrBadRequest(errParse),
 			)
 			return
 		}
 
+		r, errPre := checkIfMatch(r, cmgr, o, ID)
+		if errPre != nil {
+			errRender = renderError(w, r, o, errPre)
+			return
+		}
+
+		getter, canGet := cmgr.(crud.Getter)
+		status, body := o.success(w, r, OpDelete, canGet)
+		var e interface{}
+		if body {
+			var errG error
+			if e, errG = getter.Get(r.Context(), ID); errG != nil {
+				errRender = renderError(w, r, o, mapError(cmgr, errG))
+				return
+			}
+		}
+
 		if err := cmgr.Delete(
 			r.Context(),
 			ID,
 		); err != nil {
-			errRender = render.Render(w, r, cmgr.MapErrorToHTTPError(err))
+			errRender = renderError(w, r, o, mapError(cmgr, err))
 			return
 		}
 
-		w.WriteHeader(http.StatusAccepted)
+		if !body {
+			w.WriteHeader(status)
+			return
+		}
+		errRender = respond(w, status, e)
 	}
 }
 
 // PUTHandler will update all data for a specific entity
 func PUTHandler(
-	cmgr crud.MgrI,
+	cmgr crud.Updater,
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	d := o.decodeOptions(OpReplace)
+	d.schema = o.entitySchema(cmgr)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("PUTHandler Render: %v", errRender)
+				o.renderFailed(r, "PUTHandler", errRender)
 			}
 		}()
 
-		ID, errParse := parseIDFromRequest(r)
+		ID, errParse := parseIDFromRequest(r, cmgr, o.IDParam)
 		if errParse != nil {
-			errRender = render.Render(w, r,
+			errRender = renderError(w, r, o,
 				gohttperror.ErrBadRequest(errParse),
 			)
 			return
 		}
 
-		var payload bytes.Buffer
-		pl := io.TeeReader(r.Body, &payload)
+		r, errPre := checkIfMatch(r, cmgr, o, ID)
+		if errPre != nil {
+			errRender = renderError(w, r, o, errPre)
+			return
+		}
 
 		ent := cmgr.NewEmptyEntity()
-		if errJSON := json.NewDecoder(pl).Decode(&ent); errJSON != nil {
-			errRender = render.Render(w, r,
-				gohttperror.ErrBadRequest(errJSON),
-			)
+		payload, errD := decodeBody(w, r, d, ent)
+		if errD != nil {
+			errRender = renderError(w, r, o, errD)
+			return
+		}
+		if errV := crud.Validate(ent); errV != nil {
+			errRender = renderError(w, r, o, mapError(cmgr, errV))
 			return
 		}
 
@@ -171,61 +280,102 @@ func PUTHandler(
 			r.Context(),
 			ID,
 			ent,
-			&payload,
+			bytes.NewReader(payload),
 		)
 		if errU != nil {
-			errRender = render.Render(w, r, cmgr.MapErrorToHTTPError(errU))
+			errRender = renderError(w, r, o, mapError(cmgr, errU))
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		render.DefaultResponder(w, r, e)
+		setETag(w, cmgr, e)
+		status, body := o.success(w, r, OpReplace, true)
+		if !body {
+			w.WriteHeader(status)
+			return
+		}
+		errRender = respond(w, status, e)
 	}
 }
 
 // PATCHHandler will update specific data for a specific entity
 // Following https://tools.ietf.org/html/rfc7386
-// Content-Type: application/merge-patch+json
+// Content-Type: application/merge-patch+json, applied by a crud.Patcher
+// or https://tools.ietf.org/html/rfc6902
+// Content-Type: application/json-patch+json, applied by a crud.JSONPatcher
+// Without them, patches are applied to the entity of a crud.Getter
+// and saved with a crud.Updater
 func PATCHHandler(
-	cmgr crud.MgrI,
+	cmgr interface{},
+	opts ...Option,
 ) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	accept := acceptPatch(cmgr)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var errRender error
 		defer func() {
 			if errRender != nil {
-				log.Fatalf("PATCHHandler Render: %v", errRender)
+				o.renderFailed(r, "PATCHHandler", errRender)
 			}
 		}()
 
-		ID, errParse := parseIDFromRequest(r)
+		if accept != "" {
+			w.Header().Set("Accept-Patch", accept)
+		}
+
+		ID, errParse := parseIDFromRequest(r, cmgr, o.IDParam)
 		if errParse != nil {
-			errRender = render.Render(w, r,
+			errRender = renderError(w, r, o,
 				gohttperror.ErrBadRequest(errParse),
 			)
 			return
 		}
 
-		var payload bytes.Buffer
-		pl := io.TeeReader(r.Body, &payload)
+		mt := patchType(r, cmgr)
+		if mt == "" {
+			errRender = renderError(w, r, o, errUnsupportedPatch)
+			return
+		}
 
-		updates := crud.PartialUpdateData{}
-		if errJSON := json.NewDecoder(pl).Decode(&updates); errJSON != nil {
-			errRender = render.Render(w, r,
-				gohttperror.ErrBadRequest(errJSON),
-			)
+		r, errPre := checkIfMatch(r, cmgr, o, ID)
+		if errPre != nil {
+			errRender = renderError(w, r, o, errPre)
 			return
 		}
 
-		if err := cmgr.PartialUpdate(
-			r.Context(),
-			ID,
-			updates,
-			&payload,
-		); err != nil {
-			errRender = render.Render(w, r, cmgr.MapErrorToHTTPError(err))
+		d := o.decodeOptions(OpPatch)
+		payload, errB := readBody(w, r, d)
+		if errB != nil {
+			errRender = renderError(w, r, o, errB)
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		apply := applyMergePatch
+		if mt == JSONPatchContentType {
+			apply = applyJSONPatch
+		}
+		e, errP := apply(r.Context(), cmgr, ID, payload, d)
+		if errP != nil {
+			errRender = renderError(w, r, o, mapError(cmgr, errP))
+			return
+		}
+
+		getter, canGet := cmgr.(crud.Getter)
+		status, body := o.success(w, r, OpPatch, e != nil || canGet)
+		if body && e == nil {
+			var errG error
+			if e, errG = getter.Get(r.Context(), ID); errG != nil {
+				errRender = renderError(w, r, o, mapError(cmgr, errG))
+				return
+			}
+		}
+		if e != nil {
+			setETag(w, cmgr, e)
+		}
+		if !body {
+			w.WriteHeader(status)
+			return
+		}
+		errRender = respond(w, status, e)
 	}
 }
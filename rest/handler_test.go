@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/induzo/crud/mock"
 	"github.com/rs/xid"
@@ -479,12 +483,23 @@ func TestPATCHHandler(t *testing.T) {
 		withEmpty              bool
 		withPartialUpdateError bool
 		withBadID              bool
+		payload                string
 		wantedStatus           int
 	}{
 		{
 			name:         "working PATCH",
 			wantedStatus: http.StatusNoContent,
 		},
+		{
+			name:         "null status, non working PATCH",
+			payload:      `{"status_id": null}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "string status, non working PATCH",
+			payload:      `{"status_id": "2"}`,
+			wantedStatus: http.StatusBadRequest,
+		},
 		{
 			name:             "bad payload, non working PATCH",
 			withPayloadError: true,
@@ -522,6 +537,9 @@ func TestPATCHHandler(t *testing.T) {
 				reqID = xid.ID{}
 			}
 			payload := []byte(`{"status_id": 2}`)
+			if tt.payload != "" {
+				payload = []byte(tt.payload)
+			}
 			if tt.withPayloadError {
 				payload = payload[1:]
 			}
@@ -532,6 +550,7 @@ func TestPATCHHandler(t *testing.T) {
 				"PATCH", `http://dummy/entity`,
 				bytes.NewBuffer(payload),
 			)
+			req.Header.Set("Content-Type", MergePatchContentType)
 			req = req.WithContext(GetTestContextWithID(req.Context(), reqID))
 
 			PATCHHandler(m)(rr, req)
@@ -569,8 +588,190 @@ func BenchmarkPATCHHandler(b *testing.B) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest("PATCH", `http://dummy/ent`,
 			bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", MergePatchContentType)
 		req = req.WithContext(GetTestContextWithID(req.Context(), reqID))
 		b.StartTimer()
 		PATCHHandler(m)(rr, req)
 	}
 }
+
+func TestGETHandlerFields(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		wantedStatus int
+		wantedBody   string
+	}{
+		{
+			name:         "all fields",
+			url:          "http://dummy/entity",
+			wantedStatus: http.StatusOK,
+			wantedBody:   `{"id":"%s","status_id":3}`,
+		},
+		{
+			name:         "some fields",
+			url:          "http://dummy/entity?fields=status_id",
+			wantedStatus: http.StatusOK,
+			wantedBody:   `{"status_id":3}`,
+		},
+		{
+			name:         "unknown field",
+			url:          "http://dummy/entity?fields=status_id,pol",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "bad fields",
+			url:          "http://dummy/entity?fields=status_id,",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			ec, _ := m.Create(
+				context.Background(), &mock.Entity{StatusID: 3}, nil,
+			)
+			id := ec.(*mock.Entity).ID
+
+			for name, h := range map[string]func(
+				http.ResponseWriter, *http.Request,
+			){
+				"GETHandler":     GETHandler(m),
+				"GETListHandler": GETListHandler(m),
+			} {
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", tt.url, nil)
+				req = req.WithContext(GetTestContextWithID(req.Context(), id))
+
+				h(rr, req)
+
+				if status := rr.Code; status != tt.wantedStatus {
+					t.Errorf("%s returned wrong status code: got %v want %v",
+						name, status, tt.wantedStatus)
+					continue
+				}
+				if tt.wantedBody == "" {
+					continue
+				}
+				want := tt.wantedBody
+				if strings.Contains(want, "%s") {
+					want = fmt.Sprintf(want, id)
+				}
+				if name == "GETListHandler" {
+					want = "[" + want + "]"
+				}
+				if got := strings.TrimSpace(rr.Body.String()); got != want {
+					t.Errorf("%s returned %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerCalls(t *testing.T) {
+	t.Run("scripted responses", func(t *testing.T) {
+		m := mock.NewMgr()
+		e := &mock.Entity{ID: xid.New(), StatusID: 1}
+		m.EntityList[e.ID] = e
+		m.Script(mock.OpGet,
+			mock.Response{Err: mock.ErrNotFound},
+			mock.Response{},
+			mock.Response{Entity: &mock.Entity{ID: e.ID, StatusID: 2}},
+		)
+
+		for _, want := range []string{"", `"status_id":1`, `"status_id":2`} {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://dummy/entity", nil)
+			GETHandler(m)(rr, req.WithContext(
+				GetTestContextWithID(req.Context(), e.ID)))
+
+			if want == "" && rr.Code != http.StatusNotFound ||
+				!strings.Contains(rr.Body.String(), want) {
+				t.Errorf("GETHandler returned %d %s, want %s",
+					rr.Code, rr.Body, want)
+			}
+		}
+		m.AssertCalled(t, mock.OpGet, 3)
+		m.AssertCalledWith(t, mock.OpGet, e.ID)
+		m.AssertScriptsDone(t)
+	})
+
+	t.Run("recorded arguments", func(t *testing.T) {
+		m := mock.NewMgr()
+		payload := `{"status_id":3}`
+
+		rr := httptest.NewRecorder()
+		POSTHandler(m)(rr, httptest.NewRequest("POST", "http://dummy/entity",
+			strings.NewReader(payload)))
+		rr = httptest.NewRecorder()
+		GETListHandler(m)(rr, httptest.NewRequest("GET",
+			"http://dummy/entity?status_id=3", nil))
+
+		calls := m.Calls()
+		if len(calls) != 2 {
+			t.Fatalf("%d calls recorded, want 2", len(calls))
+		}
+		if c := calls[0]; c.Op != mock.OpCreate ||
+			string(c.Payload) != payload ||
+			c.Entity.(*mock.Entity).StatusID != 3 {
+			t.Errorf("Create called with %+v", c)
+		}
+		if c := calls[1]; c.Op != mock.OpGetList ||
+			c.Modifiers["status_id"][0] != "3" {
+			t.Errorf("GetList called with %+v", c)
+		}
+
+		m.Reset()
+		m.AssertCalled(t, mock.OpCreate, 0)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		m := mock.NewMgr()
+		m.Latency = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			10*time.Millisecond)
+		defer cancel()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://dummy/entity", nil)
+		GETHandler(m)(rr, req.WithContext(GetTestContextWithID(ctx, xid.New())))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("GETHandler returned %d after the deadline", rr.Code)
+		}
+		m.AssertCalled(t, mock.OpGet, 1)
+	})
+
+	t.Run("parallel calls", func(t *testing.T) {
+		m := mock.NewMgr()
+		m.Script(mock.OpPartialUpdate, mock.Response{Delay: time.Millisecond})
+		e := &mock.Entity{ID: xid.New()}
+		m.EntityList[e.ID] = e
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest("PATCH", "http://dummy/entity",
+					strings.NewReader(`{"status_id":4}`))
+				req.Header.Set("Content-Type", MergePatchContentType)
+				PATCHHandler(m)(rr, req.WithContext(
+					GetTestContextWithID(req.Context(), e.ID)))
+			}()
+			go func() {
+				defer wg.Done()
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "http://dummy/entity", nil)
+				GETHandler(m)(rr, req.WithContext(
+					GetTestContextWithID(req.Context(), e.ID)))
+			}()
+		}
+		wg.Wait()
+
+		m.AssertCalled(t, mock.OpPartialUpdate, 10)
+		m.AssertScriptsDone(t)
+	})
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// headWriter drops the body of the responses to HEAD requests
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// HEADHandler answers if an entity exists
+// With a crud.Exister that is neither a crud.Versioner nor a
+// crud.LastModifier, the entity is not loaded, otherwise cmgr is a
+// crud.Getter, and the response has the headers of GETHandler
+func HEADHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	ex, ok := cmgr.(crud.Exister)
+	if !ok || hasValidators(cmgr) {
+		get := GETHandler(cmgr.(crud.Getter), opts...)
+		return func(w http.ResponseWriter, r *http.Request) {
+			get(headWriter{w}, r)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, "HEADHandler", errRender)
+			}
+		}()
+		w = headWriter{w}
+
+		ID, errParse := parseIDFromRequest(r, cmgr, o.IDParam)
+		if errParse != nil {
+			errRender = renderError(w, r, o,
+				gohttperror.ErrBadRequest(errParse),
+			)
+			return
+		}
+
+		exists, errE := ex.Exists(r.Context(), ID)
+		switch {
+		case errE != nil:
+			errRender = renderError(w, r, o, mapError(cmgr, errE))
+		case !exists:
+			errRender = renderError(w, r, o, gohttperror.ErrNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+// HEADListHandler answers with the headers of a list
+// With a crud.Counter, the list is not loaded, only counted into
+// X-Total-Count, otherwise cmgr is a crud.Lister, and the response has
+// the headers of GETListHandler
+func HEADListHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	counter, ok := cmgr.(crud.Counter)
+	if !ok {
+		list := GETListHandler(cmgr.(crud.Lister), opts...)
+		return func(w http.ResponseWriter, r *http.Request) {
+			list(headWriter{w}, r)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, "HEADListHandler", errRender)
+			}
+		}()
+		w = headWriter{w}
+
+		// the query is validated as GETListHandler does
+		lm := ListModifiersFromURL(r.URL)
+		if errP := paginate(lm, o); errP != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errP))
+			return
+		}
+		count, errC := wantCount(lm)
+		if errC != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errC))
+			return
+		}
+		if _, errF := crud.ParseFields(lm[crud.ParamFields]); errF != nil {
+			errRender = renderError(w, r, o, gohttperror.ErrBadRequest(errF))
+			return
+		}
+
+		if count {
+			total, errCount := counter.Count(r.Context(), lm)
+			if errCount != nil {
+				errRender = renderError(w, r, o, mapError(cmgr, errCount))
+				return
+			}
+			w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// hasValidators returns true if cmgr gives the ETag or Last-Modified
+// of its entities, which only GETHandler sends
+func hasValidators(cmgr interface{}) bool {
+	_, versioner := cmgr.(crud.Versioner)
+	_, modifier := cmgr.(crud.LastModifier)
+	return versioner || modifier
+}
+
+// OPTIONSHandler answers with the methods allowed on an entity,
+// and the patch formats accepted
+func OPTIONSHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	ops := o.Operations & SupportedOperations(cmgr)
+	var accept string
+	if ops.Has(OpPatch) {
+		accept = acceptPatch(cmgr)
+	}
+	return optionsHandler(allowedMethods(entityRoutes, ops), accept)
+}
+
+// OPTIONSListHandler answers with the methods allowed on the collection
+func OPTIONSListHandler(
+	cmgr interface{},
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	ops := o.Operations & SupportedOperations(cmgr)
+	var accept string
+	if ops.Has(OpBulkPatch) {
+		accept = "application/json"
+	}
+	return optionsHandler(allowedMethods(collectionRoutes, ops), accept)
+}
+
+func optionsHandler(
+	allowed []string,
+	accept string,
+) func(w http.ResponseWriter, r *http.Request) {
+	allow := http.MethodOptions
+	if len(allowed) > 0 {
+		allow = strings.Join(allowed, ", ")
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if accept != "" {
+			w.Header().Set("Accept-Patch", accept)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,239 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+// existMgr is a mock manager able to tell if an entity exists,
+// failing the test if it gets one
+type existMgr struct {
+	*mock.Mgr
+	t *testing.T
+}
+
+func (m *existMgr) Exists(ctx context.Context, id crud.ID) (bool, error) {
+	_, ok := m.EntityList[id.(xid.ID)]
+	return ok, nil
+}
+
+func (m *existMgr) Get(ctx context.Context, id crud.ID) (interface{}, error) {
+	m.t.Errorf("Get called on an Exister")
+	return m.Mgr.Get(ctx, id)
+}
+
+// versionExistMgr is a versioned mock manager able to tell if an entity
+// exists, failing the test if asked
+type versionExistMgr struct {
+	*versionMgr
+	t *testing.T
+}
+
+func (m *versionExistMgr) Exists(
+	ctx context.Context,
+	id crud.ID,
+) (bool, error) {
+	m.t.Errorf("Exists called on a Versioner")
+	return true, nil
+}
+
+func TestHEADHandlers(t *testing.T) {
+	tests := []struct {
+		name         string
+		list         bool
+		exister      bool
+		versioned    bool
+		counter      bool
+		query        string
+		missing      bool
+		wantedStatus int
+		wantedHeader string
+		wantedValue  string
+		wantedNone   string
+	}{
+		{
+			name:         "entity",
+			wantedStatus: http.StatusOK,
+			wantedHeader: "ETag",
+		},
+		{
+			name:         "missing entity",
+			missing:      true,
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "entity of an Exister",
+			exister:      true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "missing entity of an Exister",
+			exister:      true,
+			missing:      true,
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "entity of a versioned Exister",
+			exister:      true,
+			versioned:    true,
+			wantedStatus: http.StatusOK,
+			wantedHeader: "ETag",
+			wantedValue:  `"0"`,
+		},
+		{
+			name:         "list",
+			list:         true,
+			wantedStatus: http.StatusOK,
+			wantedHeader: "ETag",
+		},
+		{
+			name:         "list of a Counter",
+			list:         true,
+			counter:      true,
+			wantedStatus: http.StatusOK,
+			wantedHeader: "X-Total-Count",
+			wantedValue:  "10",
+		},
+		{
+			name:         "list of a Counter without count",
+			list:         true,
+			counter:      true,
+			query:        "?count=false",
+			wantedStatus: http.StatusOK,
+			wantedNone:   "X-Total-Count",
+		},
+		{
+			name:         "list of a Counter with an invalid count",
+			list:         true,
+			counter:      true,
+			query:        "?count=maybe",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "list of a Counter with an invalid page token",
+			list:         true,
+			counter:      true,
+			query:        "?page_token=nope",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			ec, _ := m.Create(context.Background(), &mock.Entity{}, nil)
+			id := ec.(*mock.Entity).ID
+			if tt.missing {
+				id = xid.New()
+			}
+
+			var cmgr interface{} = m
+			switch {
+			case tt.versioned:
+				cmgr = &versionExistMgr{versionMgr: &versionMgr{Mgr: m}, t: t}
+			case tt.exister:
+				cmgr = &existMgr{Mgr: m, t: t}
+			case tt.counter:
+				cmgr = &countMgr{pageMgr: &pageMgr{Mgr: m}}
+			}
+			h := HEADHandler
+			if tt.list {
+				h = HEADListHandler
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("HEAD", "/e"+tt.query, nil)
+			req = req.WithContext(GetTestContextWithID(req.Context(), id))
+
+			h(cmgr)(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if rr.Body.Len() > 0 {
+				t.Errorf("handler returned a body: %q", rr.Body.String())
+			}
+			if tt.wantedNone != "" && rr.Header().Get(tt.wantedNone) != "" {
+				t.Errorf("handler returned %s", tt.wantedNone)
+			}
+			if tt.wantedHeader == "" {
+				return
+			}
+			v := rr.Header().Get(tt.wantedHeader)
+			if v == "" || tt.wantedValue != "" && v != tt.wantedValue {
+				t.Errorf("handler returned %s %q", tt.wantedHeader, v)
+			}
+		})
+	}
+}
+
+func TestOPTIONSHandlers(t *testing.T) {
+	m := mock.NewMgr()
+	readOnly := struct {
+		crud.Getter
+		crud.Lister
+	}{m, m}
+
+	tests := []struct {
+		name         string
+		handler      func(http.ResponseWriter, *http.Request)
+		wantedAllow  string
+		wantedAccept string
+	}{
+		{
+			name:        "entity",
+			handler:     OPTIONSHandler(m),
+			wantedAllow: "GET, HEAD, PUT, PATCH, DELETE, OPTIONS",
+			wantedAccept: MergePatchContentType + ", " +
+				JSONPatchContentType,
+		},
+		{
+			name:        "entity without patch",
+			handler:     OPTIONSHandler(m, WithoutOperations(OpPatch)),
+			wantedAllow: "GET, HEAD, PUT, DELETE, OPTIONS",
+		},
+		{
+			name:         "collection",
+			handler:      OPTIONSListHandler(m),
+			wantedAllow:  "GET, HEAD, POST, PATCH, DELETE, OPTIONS",
+			wantedAccept: "application/json",
+		},
+		{
+			name:        "read only entity",
+			handler:     OPTIONSHandler(readOnly),
+			wantedAllow: "GET, HEAD, OPTIONS",
+		},
+		{
+			name:        "read only collection",
+			handler:     OPTIONSListHandler(readOnly),
+			wantedAllow: "GET, HEAD, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest("OPTIONS", "/e", nil))
+
+			if rr.Code != http.StatusNoContent {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, http.StatusNoContent)
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantedAllow {
+				t.Errorf("handler returned Allow %q, want %q",
+					got, tt.wantedAllow)
+			}
+			if got := rr.Header().Get("Accept-Patch"); got != tt.wantedAccept {
+				t.Errorf("handler returned Accept-Patch %q, want %q",
+					got, tt.wantedAccept)
+			}
+		})
+	}
+}
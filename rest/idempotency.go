@@ -0,0 +1,321 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/induzo/gohttperror"
+)
+
+// DefaultIdempotencyTTL is how long the default store keeps responses
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyMaxEntries is how many responses the default store
+// keeps at most
+const DefaultIdempotencyMaxEntries = 10000
+
+// maxIdempotencyKeyLength is the maximum length of an Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+var (
+	errIdempotencyKeyTooLong = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusBadRequest,
+		StatusText:     "Bad request",
+		ErrorText:      "Idempotency-Key is too long",
+	}
+	errIdempotencyMismatch = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusUnprocessableEntity,
+		StatusText:     "Unprocessable entity",
+		ErrorText:      "Idempotency-Key was used for another request",
+	}
+	errIdempotencyInFlight = &gohttperror.ErrResponse{
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict",
+		ErrorText:      "a request with this Idempotency-Key is in progress",
+	}
+)
+
+// StoredResponse is a response stored for an Idempotency-Key,
+// its Status is 0 while its request is in flight
+type StoredResponse struct {
+	// Fingerprint identifies the payload of the request
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore stores the responses of the requests
+// with an Idempotency-Key, it has to be safe for concurrent use
+type IdempotencyStore interface {
+	// Start returns the response stored for key if any,
+	// otherwise it reserves key for a request with the fingerprint,
+	// and returns nil
+	Start(ctx context.Context, key, fingerprint string) (*StoredResponse, error)
+	// Save stores the response of the request that reserved key
+	Save(ctx context.Context, key string, res *StoredResponse) error
+	// Release frees key, reserved by a request that failed,
+	// so that it can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an in memory IdempotencyStore,
+// keeping responses for a TTL, and a maximum number of them, the oldest
+// ones being dropped first
+// It is only suitable for a single instance of a service
+type MemoryIdempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	// expiries are the keys in the order they expire, stale once
+	// their entry is released or saved again
+	expiries []memoryExpiry
+}
+
+type memoryEntry struct {
+	res     StoredResponse
+	expires time.Time
+}
+
+type memoryExpiry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns a MemoryIdempotencyStore
+// keeping responses for ttl, requests in flight included,
+// and at most maxEntries of them, 0 for no maximum
+func NewMemoryIdempotencyStore(
+	ttl time.Duration,
+	maxEntries int,
+) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*memoryEntry),
+	}
+}
+
+// Start implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Start(
+	ctx context.Context,
+	key, fingerprint string,
+) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if e, ok := s.entries[key]; ok {
+		res := e.res
+		return &res, nil
+	}
+	s.evict()
+	s.set(key, &memoryEntry{
+		res:     StoredResponse{Fingerprint: fingerprint},
+		expires: now.Add(s.ttl),
+	})
+	return nil, nil
+}
+
+// Save implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Save(
+	ctx context.Context,
+	key string,
+	res *StoredResponse,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, &memoryEntry{res: *res, expires: s.now().Add(s.ttl)})
+	return nil
+}
+
+// Release implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Release(
+	ctx context.Context,
+	key string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Len returns the number of responses stored, requests in flight included
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// set stores the entry of key, and when it expires
+func (s *MemoryIdempotencyStore) set(key string, e *memoryEntry) {
+	s.entries[key] = e
+	s.expiries = append(s.expiries, memoryExpiry{key, e.expires})
+	// drop the stale expiries once they outnumber the entries
+	if len(s.expiries) > 2*len(s.entries)+64 {
+		live := s.expiries[:0]
+		for _, x := range s.expiries {
+			if s.current(x) {
+				live = append(live, x)
+			}
+		}
+		s.expiries = live
+	}
+}
+
+// current returns true if x is the expiry of the entry of its key
+func (s *MemoryIdempotencyStore) current(x memoryExpiry) bool {
+	e, ok := s.entries[x.key]
+	return ok && e.expires.Equal(x.expires)
+}
+
+// sweep removes the expired entries
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	n := 0
+	for ; n < len(s.expiries) && !now.Before(s.expiries[n].expires); n++ {
+		if x := s.expiries[n]; s.current(x) {
+			delete(s.entries, x.key)
+		}
+	}
+	s.expiries = s.expiries[n:]
+}
+
+// evict removes the oldest entries until there is room for one more
+func (s *MemoryIdempotencyStore) evict() {
+	n := 0
+	for ; s.maxEntries > 0 && len(s.entries) >= s.maxEntries &&
+		n < len(s.expiries); n++ {
+		if x := s.expiries[n]; s.current(x) {
+			delete(s.entries, x.key)
+		}
+	}
+	s.expiries = s.expiries[n:]
+}
+
+// idempotent makes the requests of h with an Idempotency-Key
+// replay the response of the first one
+// A retry with another payload is answered with 422 Unprocessable Entity,
+// and while the first one is in flight with 409 Conflict
+// Server errors are not stored, the request can be retried
+func (o *Options) idempotent(
+	handler string,
+	op Operation,
+	h http.HandlerFunc,
+) http.HandlerFunc {
+	if o.Idempotency == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			h(w, r)
+			return
+		}
+
+		var errRender error
+		defer func() {
+			if errRender != nil {
+				o.renderFailed(r, handler, errRender)
+			}
+		}()
+
+		if len(key) > maxIdempotencyKeyLength {
+			errRender = renderError(w, r, o, errIdempotencyKeyTooLong)
+			return
+		}
+		payload, errB := readBody(w, r, o.decodeOptions(op))
+		if errB != nil {
+			errRender = renderError(w, r, o, errB)
+			return
+		}
+		r.Body = readCloser{Reader: bytes.NewReader(payload), Closer: r.Body}
+		sum := sha256.Sum256(payload)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		ctx := r.Context()
+		// keys are scoped to the resource, and to the client if set
+		key = r.Method + " " + r.URL.Path + " " + key
+		if o.IdempotencyScope != nil {
+			key = o.IdempotencyScope(r) + " " + key
+		}
+		stored, err := o.Idempotency.Start(ctx, key, fingerprint)
+		switch {
+		case err != nil:
+			errRender = renderError(w, r, o, gohttperror.ErrInternal(err))
+			return
+		case stored == nil:
+		case stored.Fingerprint != fingerprint:
+			errRender = renderError(w, r, o, errIdempotencyMismatch)
+			return
+		case stored.Status == 0:
+			errRender = renderError(w, r, o, errIdempotencyInFlight)
+			return
+		default:
+			for k, v := range stored.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			_, errRender = w.Write(stored.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		saved := false
+		defer func() {
+			if !saved {
+				if err := o.Idempotency.Release(ctx, key); err != nil {
+					o.renderFailed(r, handler, err)
+				}
+			}
+		}()
+
+		h(rec, r)
+
+		if rec.status == 0 || rec.status >= 500 {
+			return
+		}
+		errRender = o.Idempotency.Save(ctx, key, &StoredResponse{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      w.Header().Clone(),
+			Body:        rec.body.Bytes(),
+		})
+		saved = errRender == nil
+	}
+}
+
+// responseRecorder records the response it writes
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
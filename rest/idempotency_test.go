@@ -0,0 +1,247 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/induzo/crud/mock"
+)
+
+func TestIdempotency(t *testing.T) {
+	type request struct {
+		key          string
+		tenant       string
+		body         string
+		createError  bool
+		wantedStatus int
+		wantedReplay bool
+	}
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		inFlight      bool
+		expire        bool
+		requests      []request
+		wantedCreated int
+	}{
+		{
+			name: "retry",
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201,
+					wantedReplay: true},
+				{key: "k2", body: `{"status_id": 1}`, wantedStatus: 201},
+			},
+			wantedCreated: 2,
+		},
+		{
+			name: "without key",
+			requests: []request{
+				{body: `{"status_id": 1}`, wantedStatus: 201},
+				{body: `{"status_id": 1}`, wantedStatus: 201},
+			},
+			wantedCreated: 2,
+		},
+		{
+			name: "another payload",
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+				{key: "k", body: `{"status_id": 2}`, wantedStatus: 422},
+			},
+			wantedCreated: 1,
+		},
+		{
+			name: "client errors are replayed",
+			requests: []request{
+				{key: "k", body: `{"status_id": "1"}`, wantedStatus: 400},
+				{key: "k", body: `{"status_id": "1"}`, wantedStatus: 400,
+					wantedReplay: true},
+			},
+		},
+		{
+			name: "server errors are not stored",
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, createError: true,
+					wantedStatus: 500},
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+			},
+			wantedCreated: 1,
+		},
+		{
+			name:     "in flight",
+			inFlight: true,
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 409},
+			},
+		},
+		{
+			name:   "expired",
+			expire: true,
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+			},
+			wantedCreated: 2,
+		},
+		{
+			name: "scoped keys",
+			opts: []Option{WithIdempotencyScope(func(r *http.Request) string {
+				return r.Header.Get("X-Tenant")
+			})},
+			requests: []request{
+				{key: "k", tenant: "a", body: `{"status_id": 1}`,
+					wantedStatus: 201},
+				{key: "k", tenant: "b", body: `{"status_id": 1}`,
+					wantedStatus: 201},
+				{key: "k", tenant: "a", body: `{"status_id": 1}`,
+					wantedStatus: 201, wantedReplay: true},
+			},
+			wantedCreated: 2,
+		},
+		{
+			name: "disabled",
+			opts: []Option{WithIdempotencyStore(nil)},
+			requests: []request{
+				{key: "k", body: `{"status_id": 1}`, wantedStatus: 201},
+				{key: "k", body: `{"status_id": 2}`, wantedStatus: 201},
+			},
+			wantedCreated: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			store := NewMemoryIdempotencyStore(time.Hour, 0)
+			now := time.Now()
+			store.now = func() time.Time { return now }
+			if tt.inFlight {
+				sum := sha256.Sum256([]byte(tt.requests[0].body))
+				_, _ = store.Start(context.Background(), "POST /e k",
+					hex.EncodeToString(sum[:]))
+			}
+			opts := append([]Option{WithIdempotencyStore(store)}, tt.opts...)
+			h := POSTHandler(m, opts...)
+
+			var first *httptest.ResponseRecorder
+			for i, req := range tt.requests {
+				m.WantCreateError = req.createError
+				if tt.expire {
+					now = now.Add(2 * time.Hour)
+				}
+
+				rr := httptest.NewRecorder()
+				r := httptest.NewRequest("POST", "/e",
+					bytes.NewBufferString(req.body))
+				if req.key != "" {
+					r.Header.Set("Idempotency-Key", req.key)
+				}
+				r.Header.Set("X-Tenant", req.tenant)
+
+				h(rr, r)
+
+				if rr.Code != req.wantedStatus {
+					t.Errorf("request %d: wrong status code: got %v want %v",
+						i, rr.Code, req.wantedStatus)
+				}
+				replayed := rr.Header().Get("Idempotent-Replayed") == "true"
+				if replayed != req.wantedReplay {
+					t.Errorf("request %d: replayed %v", i, replayed)
+				}
+				if replayed && (rr.Body.String() != first.Body.String() ||
+					rr.Header().Get("Location") !=
+						first.Header().Get("Location")) {
+					t.Errorf("request %d: replayed %q, want %q", i,
+						rr.Body.String(), first.Body.String())
+				}
+				if first == nil {
+					first = rr
+				}
+			}
+
+			if len(m.EntityList) != tt.wantedCreated {
+				t.Errorf("manager created %d entities, want %d",
+					len(m.EntityList), tt.wantedCreated)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/e",
+		bytes.NewBufferString(`{"status_id": 1}`))
+	r.Header.Set("Idempotency-Key", string(make([]byte, 256)))
+
+	POSTHandler(mock.NewMgr())(rr, r)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestResourceIdempotency(t *testing.T) {
+	m := mock.NewMgr()
+	res := NewResource(m, WithPath("/e"))
+
+	for i, tc := range []struct {
+		body         string
+		wantedStatus int
+	}{
+		{`{"status_id": 1}`, http.StatusCreated},
+		// the bulk create shares the keys of the single one
+		{`[{"status_id": 1}]`, http.StatusUnprocessableEntity},
+	} {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/e", bytes.NewBufferString(tc.body))
+		r.Header.Set("Idempotency-Key", "k")
+
+		res.ServeHTTP(rr, r)
+
+		if rr.Code != tc.wantedStatus {
+			t.Errorf("request %d: wrong status code: got %v want %v",
+				i, rr.Code, tc.wantedStatus)
+		}
+	}
+	if len(m.EntityList) != 1 {
+		t.Errorf("manager created %d entities, want 1", len(m.EntityList))
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryIdempotencyStore(time.Hour, 2)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b"} {
+		if res, _ := s.Start(ctx, key, "f"); res != nil {
+			t.Errorf("Start(%s) = %+v, want nil", key, res)
+		}
+	}
+	now = now.Add(time.Minute)
+	_ = s.Save(ctx, "a", &StoredResponse{Fingerprint: "f", Status: 201})
+
+	// b is the oldest one
+	_, _ = s.Start(ctx, "c", "f")
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", s.Len())
+	}
+	if res, _ := s.Start(ctx, "a", "f"); res == nil || res.Status != 201 {
+		t.Errorf("Start(a) = %+v, want the saved response", res)
+	}
+
+	now = now.Add(2 * time.Hour)
+	_, _ = s.Start(ctx, "d", "f")
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d after the TTL, want 1", s.Len())
+	}
+}
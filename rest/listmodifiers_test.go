@@ -1,11 +1,15 @@
 package rest
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
 
 	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
 )
 
 func TestListModifiersFromURL(t *testing.T) {
@@ -38,3 +42,56 @@ func TestListModifiersFromURL(t *testing.T) {
 		})
 	}
 }
+
+// queryMgr is a mock manager parsing its list modifiers strictly
+type queryMgr struct {
+	*mock.Mgr
+}
+
+func (m queryMgr) GetList(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (interface{}, error) {
+	if _, err := crud.ParseQuery(lm, crud.QuerySpec{
+		Filterable: map[string][]crud.Operator{"status_id": nil},
+	}); err != nil {
+		return nil, err
+	}
+	return m.Mgr.GetList(ctx, lm)
+}
+
+func TestGETListHandlerQueryError(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		wantedStatus int
+	}{
+		{
+			name:         "valid query",
+			url:          "http://dummy/entity?status_id[gte]=1",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "invalid query",
+			url:          "http://dummy/entity?pol=lux",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := queryMgr{mock.NewMgr()}
+			_, _ = m.Create(context.Background(), m.NewEmptyEntity(), nil)
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.url, nil)
+
+			GETListHandler(m)(rr, req)
+
+			if status := rr.Code; status != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.wantedStatus)
+			}
+		})
+	}
+}
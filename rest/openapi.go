@@ -0,0 +1,598 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification
+// the documents follow, its schemas being JSON Schemas 2020-12
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIPath is the path OpenAPI.Mount serves the document on
+const OpenAPIPath = "/openapi.json"
+
+// OpenAPI generates the OpenAPI document of resources,
+// they add themselves to it with WithOpenAPI
+// The document is generated from what the handlers do with the options of
+// the resources, and the interfaces their manager implements, the schemas
+// are reflected from the entities of NewEmptyEntity, see
+// crud.SchemaReflector
+type OpenAPI struct {
+	// Info describes the API
+	Info OpenAPIInfo
+	// Servers are the base URLs of the API,
+	// the paths of the resources being relative to them
+	Servers []OpenAPIServer
+
+	mu        sync.Mutex
+	resources []*Resource
+}
+
+// NewOpenAPI creates an OpenAPI document titled title,
+// version being the version of the API
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{Info: OpenAPIInfo{Title: title, Version: version}}
+}
+
+// Add adds resources to the document
+func (api *OpenAPI) Add(res ...*Resource) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.resources = append(api.resources, res...)
+}
+
+// Mount registers the handler of the document on r, at OpenAPIPath
+func (api *OpenAPI) Mount(r chi.Router) {
+	r.Method(http.MethodGet, OpenAPIPath, api)
+}
+
+// ServeHTTP renders the document as JSON
+func (api *OpenAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := respond(w, http.StatusOK, api.Document()); err != nil {
+		logRenderFailure(slog.Default(), r, "OpenAPI", err)
+	}
+}
+
+// Document generates the document of the resources added so far
+func (api *OpenAPI) Document() *OpenAPIDocument {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	b := &openAPIBuilder{
+		doc: &OpenAPIDocument{
+			OpenAPI: OpenAPIVersion,
+			Info:    api.Info,
+			Servers: api.Servers,
+			Paths:   make(map[string]OpenAPIPathItem),
+		},
+		sr:  &crud.SchemaReflector{RefPrefix: "#/components/schemas/"},
+		ids: make(map[string]int),
+	}
+	for _, res := range api.resources {
+		b.resource(res)
+	}
+	b.doc.Components.Schemas = b.sr.Defs
+	return b.doc
+}
+
+// OpenAPIDocument is an OpenAPI document,
+// restricted to what the generator uses
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+// OpenAPIInfo describes an API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIServer is a base URL of an API
+type OpenAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem are the operations of a path, by lower case method
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+// OpenAPIOperation is an operation of a path
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a path, query or header parameter of an operation
+type OpenAPIParameter struct {
+	Name        string       `json:"name"`
+	In          string       `json:"in"`
+	Description string       `json:"description,omitempty"`
+	Required    bool         `json:"required,omitempty"`
+	Style       string       `json:"style,omitempty"`
+	Explode     *bool        `json:"explode,omitempty"`
+	Schema      *crud.Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody is the body of a request, by media type
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]OpenAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIHeader is a header of a response
+type OpenAPIHeader struct {
+	Description string       `json:"description,omitempty"`
+	Schema      *crud.Schema `json:"schema"`
+}
+
+// OpenAPIMediaType is the schema of a body
+type OpenAPIMediaType struct {
+	Schema *crud.Schema `json:"schema"`
+}
+
+// OpenAPIComponents are the definitions shared by the operations
+type OpenAPIComponents struct {
+	Schemas map[string]*crud.Schema `json:"schemas,omitempty"`
+}
+
+// openAPIBuilder adds the operations of resources to doc
+type openAPIBuilder struct {
+	doc *OpenAPIDocument
+	sr  *crud.SchemaReflector
+	// ids counts the operation ids, to number the duplicates
+	ids map[string]int
+}
+
+// stringSchema and integerSchema return new schemas, the document
+// being the caller's to modify
+func stringSchema() *crud.Schema {
+	return &crud.Schema{Type: crud.SchemaType{"string"}}
+}
+
+func integerSchema() *crud.Schema {
+	return &crud.Schema{Type: crud.SchemaType{"integer"}, Minimum: new(float64)}
+}
+
+// openAPIResource is a resource being documented
+type openAPIResource struct {
+	*Resource
+	b      *openAPIBuilder
+	name   string
+	tag    string
+	entity *crud.Schema
+}
+
+func (b *openAPIBuilder) resource(res *Resource) {
+	r := &openAPIResource{
+		Resource: res,
+		b:        b,
+		name:     strings.Trim(res.o.Path, "/"),
+		entity:   &crud.Schema{},
+	}
+	if f, ok := res.cmgr.(crud.EntityFactory); ok {
+		t := reflect.TypeOf(f.NewEmptyEntity())
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		r.entity = b.sr.ReflectEntity(t)
+		if t != nil && t.Name() != "" {
+			r.name = t.Name()
+		}
+	}
+	r.tag = strings.Trim(res.o.Path, "/")
+	if r.tag == "" {
+		r.tag = r.name
+	}
+
+	collection := "/" + strings.Trim(res.o.Path, "/")
+	item := strings.TrimSuffix(collection, "/") + "/{" + res.o.IDParam + "}"
+	schema := strings.TrimSuffix(collection, "/") + SchemaPath
+	for p, ops := range map[string]OpenAPIPathItem{
+		collection: r.collectionOperations(),
+		item:       r.entityOperations(),
+		schema:     r.schemaOperations(),
+	} {
+		if len(ops) == 0 {
+			continue
+		}
+		if b.doc.Paths[p] == nil {
+			b.doc.Paths[p] = make(OpenAPIPathItem)
+		}
+		for method, op := range ops {
+			b.doc.Paths[p][method] = op
+		}
+	}
+}
+
+func (r *openAPIResource) collectionOperations() OpenAPIPathItem {
+	ops := make(OpenAPIPathItem)
+	if r.ops.Has(OpList) {
+		ops["get"] = r.list()
+	}
+	if r.ops&(OpCreate|OpBulkCreate) != 0 {
+		ops["post"] = r.create()
+	}
+	if r.ops.Has(OpBulkPatch) {
+		op := r.operation("bulkPatch", "Bulk patch "+r.name)
+		op.RequestBody = r.jsonBody(&crud.Schema{
+			Type:  crud.SchemaType{"array"},
+			Items: r.b.sr.Reflect(reflect.TypeOf(BulkPatchItem{})),
+		})
+		r.bulkResponses(op)
+		r.bulkPreconditions(op)
+		ops["patch"] = op
+	}
+	if r.ops.Has(OpBulkDelete) {
+		op := r.operation("bulkDelete", "Bulk delete "+r.name)
+		explode := false
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:     "ids",
+			In:       "query",
+			Required: true,
+			Style:    "form",
+			Explode:  &explode,
+			Schema: &crud.Schema{
+				Type:  crud.SchemaType{"array"},
+				Items: stringSchema(),
+			},
+		})
+		r.bulkResponses(op)
+		r.bulkPreconditions(op)
+		ops["delete"] = op
+	}
+	return ops
+}
+
+func (r *openAPIResource) entityOperations() OpenAPIPathItem {
+	ops := make(OpenAPIPathItem)
+	if r.ops.Has(OpGet) {
+		ops["get"] = r.get()
+	}
+	if r.ops.Has(OpReplace) {
+		op := r.operation("replace", "Replace "+r.name)
+		op.RequestBody = r.jsonBody(r.entity)
+		r.write(op, OpReplace)
+		r.errors(op, http.StatusBadRequest, http.StatusNotFound,
+			http.StatusRequestEntityTooLarge,
+			http.StatusUnprocessableEntity)
+		r.decodeErrors(op, OpReplace)
+		ops["put"] = op
+	}
+	if r.ops.Has(OpPatch) {
+		ops["patch"] = r.patch()
+	}
+	if r.ops.Has(OpDelete) {
+		op := r.operation("delete", "Delete "+r.name)
+		r.write(op, OpDelete)
+		r.errors(op, http.StatusBadRequest, http.StatusNotFound)
+		ops["delete"] = op
+	}
+	for _, op := range ops {
+		op.Parameters = append([]OpenAPIParameter{{
+			Name:     r.o.IDParam,
+			In:       "path",
+			Required: true,
+			Schema:   stringSchema(),
+		}}, op.Parameters...)
+	}
+	return ops
+}
+
+func (r *openAPIResource) schemaOperations() OpenAPIPathItem {
+	if !r.ops.Has(OpSchema) {
+		return nil
+	}
+	op := r.operation("getSchema", "Get the JSON Schema of "+r.name)
+	op.Responses["200"] = &OpenAPIResponse{
+		Description: "the JSON Schema, draft 2020-12",
+		Headers:     map[string]OpenAPIHeader{"ETag": {Schema: stringSchema()}},
+		Content: jsonContent(&crud.Schema{
+			Type: crud.SchemaType{"object"},
+		}),
+	}
+	op.Responses["304"] = &OpenAPIResponse{Description: "Not Modified"}
+	return OpenAPIPathItem{"get": op}
+}
+
+func (r *openAPIResource) list() *OpenAPIOperation {
+	op := r.operation("list", "List "+r.name)
+	op.Description = "The other query parameters filter the list, " +
+		"as field=value or field[op]=value, op being one of eq, ne, " +
+		"gt, gte, lt, lte, in and contains"
+	op.Parameters = append(op.Parameters,
+		query("limit", "maximum number of entities", integerSchema()),
+		query("offset", "number of entities to skip", integerSchema()),
+		query(crud.ParamOrderBy,
+			"comma separated fields to sort on, followed by DESC "+
+				"for a descending order", stringSchema()),
+		query(crud.ParamPageToken, "token of the page to get, from "+
+			"the Link header of the previous response", stringSchema()),
+		fieldsParameter(),
+		header("If-None-Match", "ETag of the list the client has", false),
+	)
+
+	list := &crud.Schema{Type: crud.SchemaType{"array"}, Items: r.entity}
+	if r.o.ListEnvelope {
+		list = &crud.Schema{
+			Type: crud.SchemaType{"object"},
+			Properties: map[string]*crud.Schema{
+				"data": list,
+				"meta": r.b.sr.Reflect(reflect.TypeOf(ListMeta{})),
+			},
+		}
+	}
+	ok := &OpenAPIResponse{
+		Description: "the list",
+		Headers: map[string]OpenAPIHeader{
+			"ETag": {Schema: stringSchema()},
+			"Link": {
+				Description: "links to the next and previous pages",
+				Schema:      stringSchema(),
+			},
+		},
+		Content: jsonContent(list),
+	}
+	if _, counts := r.cmgr.(crud.Counter); counts {
+		op.Parameters = append(op.Parameters, query(crud.ParamCount,
+			"false to skip the count of the entities",
+			&crud.Schema{Type: crud.SchemaType{"boolean"}}))
+		ok.Headers["X-Total-Count"] = OpenAPIHeader{
+			Description: "number of entities of the list",
+			Schema:      integerSchema(),
+		}
+	}
+	r.lastModified(ok)
+	op.Responses["200"] = ok
+	op.Responses["304"] = &OpenAPIResponse{Description: "Not Modified"}
+	r.errors(op, http.StatusBadRequest)
+	return op
+}
+
+func (r *openAPIResource) create() *OpenAPIOperation {
+	op := r.operation("create", "Create "+r.name)
+
+	var bodies []*crud.Schema
+	if r.ops.Has(OpCreate) {
+		bodies = append(bodies, r.entity)
+		r.write(op, OpCreate)
+		resp := op.Responses[strconv.Itoa(r.o.Statuses[OpCreate])]
+		resp.Headers = map[string]OpenAPIHeader{
+			"Location": {
+				Description: "URL of the entity",
+				Schema:      stringSchema(),
+			},
+		}
+	}
+	if r.ops.Has(OpBulkCreate) {
+		bodies = append(bodies, &crud.Schema{
+			Type:  crud.SchemaType{"array"},
+			Items: r.entity,
+		})
+		r.bulkResponses(op)
+	}
+	body := bodies[0]
+	if len(bodies) > 1 {
+		body = &crud.Schema{AnyOf: bodies}
+	}
+	op.RequestBody = r.jsonBody(body)
+
+	if r.o.Idempotency != nil {
+		op.Parameters = append(op.Parameters, header("Idempotency-Key",
+			"retries with the same key get the first response", false))
+		r.errors(op, http.StatusConflict)
+	}
+	r.errors(op, http.StatusBadRequest, http.StatusRequestEntityTooLarge,
+		http.StatusUnprocessableEntity)
+	r.decodeErrors(op, OpCreate)
+	return op
+}
+
+func (r *openAPIResource) get() *OpenAPIOperation {
+	op := r.operation("get", "Get "+r.name)
+	op.Parameters = append(op.Parameters,
+		fieldsParameter(),
+		header("If-None-Match", "ETag of the entity the client has", false),
+	)
+	ok := &OpenAPIResponse{
+		Description: "the entity",
+		Headers:     map[string]OpenAPIHeader{"ETag": {Schema: stringSchema()}},
+		Content:     jsonContent(r.entity),
+	}
+	r.lastModified(ok)
+	op.Responses["200"] = ok
+	op.Responses["304"] = &OpenAPIResponse{Description: "Not Modified"}
+	r.errors(op, http.StatusBadRequest, http.StatusNotFound)
+	return op
+}
+
+func (r *openAPIResource) patch() *OpenAPIOperation {
+	op := r.operation("patch", "Patch "+r.name)
+	op.RequestBody = &OpenAPIRequestBody{
+		Required: true,
+		Content:  make(map[string]OpenAPIMediaType),
+	}
+	if supportsMergePatch(r.cmgr) {
+		op.RequestBody.Content[MergePatchContentType] = OpenAPIMediaType{
+			Schema: &crud.Schema{
+				Type:        crud.SchemaType{"object"},
+				Description: "RFC 7386 merge patch of the entity",
+			},
+		}
+	}
+	if supportsJSONPatch(r.cmgr) {
+		op.RequestBody.Content[JSONPatchContentType] = OpenAPIMediaType{
+			Schema: &crud.Schema{
+				Type:        crud.SchemaType{"array"},
+				Description: "RFC 6902 JSON patch of the entity",
+				Items: r.b.sr.Reflect(
+					reflect.TypeOf(crud.PatchOperation{}),
+				),
+			},
+		}
+	}
+	r.write(op, OpPatch)
+	r.errors(op, http.StatusBadRequest, http.StatusNotFound,
+		http.StatusConflict, http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	return op
+}
+
+// operation returns a new operation of the resource, its id being verb
+// followed by the name of the entity
+func (r *openAPIResource) operation(verb, summary string) *OpenAPIOperation {
+	id := verb + r.name
+	if r.b.ids[id]++; r.b.ids[id] > 1 {
+		id += strconv.Itoa(r.b.ids[id])
+	}
+	op := &OpenAPIOperation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        []string{r.tag},
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+	op.Responses["default"] = r.errorResponse("unexpected error")
+	return op
+}
+
+// write adds the success response of the operation op changing an
+// entity, and the headers of its preconditions and preferences
+func (r *openAPIResource) write(op *OpenAPIOperation, o Operation) {
+	status := r.o.Statuses[o]
+	resp := &OpenAPIResponse{Description: http.StatusText(status)}
+	if status == http.StatusOK || status == http.StatusCreated {
+		resp.Content = jsonContent(r.entity)
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+	op.Parameters = append(op.Parameters, header("Prefer",
+		"return=minimal or return=representation, "+
+			"to get the entity in the response or not", false))
+
+	if o == OpCreate {
+		return
+	}
+	_, versions := r.cmgr.(crud.Versioner)
+	if versions || r.o.RequirePreconditions {
+		op.Parameters = append(op.Parameters, header("If-Match",
+			"ETag of the entity the change is based on",
+			r.o.RequirePreconditions))
+		r.errors(op, http.StatusPreconditionFailed)
+	}
+	if r.o.RequirePreconditions {
+		r.errors(op, http.StatusPreconditionRequired)
+	}
+}
+
+// decodeErrors adds the errors of the decoding of the body of op
+func (r *openAPIResource) decodeErrors(op *OpenAPIOperation, o Operation) {
+	if r.o.decodeOptions(o).RequireJSON {
+		r.errors(op, http.StatusUnsupportedMediaType)
+	}
+}
+
+// bulkPreconditions adds the error of the bulk operation op refused
+// when preconditions are required
+func (r *openAPIResource) bulkPreconditions(op *OpenAPIOperation) {
+	if r.o.RequirePreconditions {
+		r.errors(op, http.StatusPreconditionRequired)
+	}
+}
+
+func (r *openAPIResource) bulkResponses(op *OpenAPIOperation) {
+	op.Responses[strconv.Itoa(http.StatusMultiStatus)] = &OpenAPIResponse{
+		Description: "the results of the items, in order",
+		Content: jsonContent(
+			r.b.sr.Reflect(reflect.TypeOf(BulkResponse{})),
+		),
+	}
+	r.errors(op, http.StatusBadRequest, http.StatusRequestEntityTooLarge)
+}
+
+func (r *openAPIResource) lastModified(resp *OpenAPIResponse) {
+	if _, ok := r.cmgr.(crud.LastModifier); ok {
+		resp.Headers["Last-Modified"] = OpenAPIHeader{Schema: stringSchema()}
+	}
+}
+
+// errors adds the error responses of statuses to op
+func (r *openAPIResource) errors(op *OpenAPIOperation, statuses ...int) {
+	for _, s := range statuses {
+		op.Responses[strconv.Itoa(s)] = r.errorResponse(http.StatusText(s))
+	}
+}
+
+func (r *openAPIResource) errorResponse(description string) *OpenAPIResponse {
+	if r.o.GoHTTPErrors {
+		return &OpenAPIResponse{
+			Description: description,
+			Content: jsonContent(r.b.sr.Reflect(
+				reflect.TypeOf(gohttperror.ErrResponse{}),
+			)),
+		}
+	}
+	return &OpenAPIResponse{
+		Description: description,
+		Content: map[string]OpenAPIMediaType{
+			ProblemContentType: {
+				Schema: r.b.sr.Reflect(reflect.TypeOf(crud.Problem{})),
+			},
+		},
+	}
+}
+
+func (r *openAPIResource) jsonBody(s *crud.Schema) *OpenAPIRequestBody {
+	return &OpenAPIRequestBody{Required: true, Content: jsonContent(s)}
+}
+
+func jsonContent(s *crud.Schema) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{"application/json": {Schema: s}}
+}
+
+func query(name, description string, s *crud.Schema) OpenAPIParameter {
+	return OpenAPIParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      s,
+	}
+}
+
+func fieldsParameter() OpenAPIParameter {
+	return query(crud.ParamFields,
+		"comma separated fields to render, all by default", stringSchema())
+}
+
+func header(name, description string, required bool) OpenAPIParameter {
+	return OpenAPIParameter{
+		Name:        name,
+		In:          "header",
+		Description: description,
+		Required:    required,
+		Schema:      stringSchema(),
+	}
+}
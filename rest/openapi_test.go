@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+)
+
+func TestOpenAPI(t *testing.T) {
+	m := mock.NewMgr()
+
+	tests := []struct {
+		name          string
+		cmgr          interface{}
+		opts          []Option
+		wantedMethods map[string][]string
+		wantedSchemas []string
+		wantedStatus  map[string]string
+	}{
+		{
+			name: "manager",
+			cmgr: m,
+			opts: []Option{WithPath("/e")},
+			wantedMethods: map[string][]string{
+				"/e":         {"delete", "get", "patch", "post"},
+				"/e/{ID}":    {"delete", "get", "patch", "put"},
+				"/e/_schema": {"get"},
+			},
+			wantedSchemas: []string{
+				"BulkItem", "BulkPatchItem", "BulkResponse", "Entity",
+				"PatchOperation", "Problem",
+			},
+			wantedStatus: map[string]string{
+				"createEntity":  "201",
+				"deleteEntity":  "202",
+				"patchEntity":   "204",
+				"replaceEntity": "200",
+			},
+		},
+		{
+			name: "read only manager",
+			cmgr: struct {
+				crud.Getter
+				crud.Lister
+			}{m, m},
+			opts: []Option{
+				WithPath("/e"), WithIDParam("entityID"), WithGoHTTPErrors(),
+			},
+			wantedMethods: map[string][]string{
+				"/e":            {"get"},
+				"/e/{entityID}": {"get"},
+			},
+			wantedSchemas: []string{"ErrResponse"},
+		},
+		{
+			name: "custom statuses",
+			cmgr: m,
+			opts: []Option{
+				WithOperations(OpCreate | OpDelete | OpSchema),
+				WithStatus(OpDelete, http.StatusNoContent),
+			},
+			wantedMethods: map[string][]string{
+				"/":        {"post"},
+				"/{ID}":    {"delete"},
+				"/_schema": {"get"},
+			},
+			wantedSchemas: []string{"Entity", "Problem"},
+			wantedStatus: map[string]string{
+				"createEntity": "201",
+				"deleteEntity": "204",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := NewOpenAPI("test", "1.0.0")
+			NewResource(tt.cmgr, append(tt.opts, WithOpenAPI(api))...)
+
+			doc := api.Document()
+			if doc.OpenAPI != OpenAPIVersion || doc.Info.Title != "test" {
+				t.Errorf("Document() is %s for %s",
+					doc.OpenAPI, doc.Info.Title)
+			}
+
+			ids := map[string]*OpenAPIOperation{}
+			for p, wanted := range tt.wantedMethods {
+				var methods []string
+				for method, op := range doc.Paths[p] {
+					methods = append(methods, method)
+					ids[op.OperationID] = op
+				}
+				sort.Strings(methods)
+				if !reflect.DeepEqual(methods, wanted) {
+					t.Errorf("Document() has %v on %s, want %v",
+						methods, p, wanted)
+				}
+			}
+			if len(doc.Paths) != len(tt.wantedMethods) {
+				t.Errorf("Document() has %d paths, want %d",
+					len(doc.Paths), len(tt.wantedMethods))
+			}
+
+			var schemas []string
+			for name := range doc.Components.Schemas {
+				schemas = append(schemas, name)
+			}
+			sort.Strings(schemas)
+			if !reflect.DeepEqual(schemas, tt.wantedSchemas) {
+				t.Errorf("Document() has the schemas %v, want %v",
+					schemas, tt.wantedSchemas)
+			}
+
+			for id, status := range tt.wantedStatus {
+				op, ok := ids[id]
+				if !ok {
+					t.Errorf("Document() has no %s operation", id)
+					continue
+				}
+				if _, ok := op.Responses[status]; !ok {
+					t.Errorf("%s has no %s response", id, status)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIServeHTTP(t *testing.T) {
+	api := NewOpenAPI("test", "1.0.0")
+	r := chi.NewRouter()
+	Mount(r, mock.NewMgr(), WithPath("/e"), WithOpenAPI(api))
+	api.Mount(r)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", OpenAPIPath, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v",
+			rr.Code, http.StatusOK)
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	if doc.OpenAPI != OpenAPIVersion || len(doc.Paths["/e/{ID}"]) != 4 {
+		t.Errorf("handler returned %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,338 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/induzo/crud"
+)
+
+// Operation identifies a REST operation on a resource,
+// they can be combined as flags: OpGet | OpList
+type Operation uint
+
+const (
+	// OpList is GET on the collection
+	OpList Operation = 1 << iota
+	// OpCreate is POST on the collection
+	OpCreate
+	// OpGet is GET on an entity
+	OpGet
+	// OpReplace is PUT on an entity
+	OpReplace
+	// OpPatch is PATCH on an entity
+	OpPatch
+	// OpDelete is DELETE on an entity
+	OpDelete
+	// OpBulkCreate is POST of an array on the collection
+	OpBulkCreate
+	// OpBulkPatch is PATCH on the collection
+	OpBulkPatch
+	// OpBulkDelete is DELETE on the collection
+	OpBulkDelete
+	// OpSchema is GET on the JSON Schema of the entities, /path/_schema
+	OpSchema
+
+	// OpBulk are the bulk operations
+	OpBulk = OpBulkCreate | OpBulkPatch | OpBulkDelete
+	// OpAll are all the above operations
+	OpAll = OpList | OpCreate | OpGet | OpReplace | OpPatch | OpDelete |
+		OpBulk | OpSchema
+)
+
+// Has returns true if all the operations of o2 are in o
+func (o Operation) Has(o2 Operation) bool {
+	return o&o2 == o2
+}
+
+// split returns the single operations of o
+func (o Operation) split() []Operation {
+	var ops []Operation
+	for op := OpList; op <= OpSchema; op <<= 1 {
+		if o.Has(op) {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// Options holds the settings of the REST handlers
+type Options struct {
+	// Path is the path a Resource is mounted on
+	Path string
+	// IDParam is the chi URL param holding the entity id
+	IDParam string
+	// Operations are the operations a Resource mounts
+	Operations Operation
+	// CursorKey signs the cursors of crud.Page into page tokens
+	CursorKey []byte
+	// DefaultPageSize is the limit of lists without one, 0 for none
+	DefaultPageSize int
+	// MaxPageSize is the maximum limit of lists, 0 for none
+	MaxPageSize int
+	// ListEnvelope wraps lists in a ListEnvelope
+	ListEnvelope bool
+	// GoHTTPErrors renders errors as gohttperror.ErrResponse
+	// instead of RFC 7807 problem details
+	GoHTTPErrors bool
+	// RequirePreconditions answers 428 Precondition Required to PUT,
+	// PATCH and DELETE requests without If-Match
+	RequirePreconditions bool
+	// Logger logs the failures to render a response
+	Logger *slog.Logger
+	// OnRenderError, if set, is called when rendering a response fails,
+	// after logging it, the client is usually gone by then
+	OnRenderError func(r *http.Request, err error)
+	// Decode is how request bodies are decoded
+	Decode DecodeOptions
+	// OperationDecode overrides Decode for some operations
+	OperationDecode map[Operation]DecodeOptions
+	// Statuses are the success statuses of the operations
+	// creating, replacing, patching and deleting entities
+	Statuses map[Operation]int
+	// MaxBulkSize is the maximum number of items of bulk operations
+	MaxBulkSize int
+	// Idempotency stores the responses of POST requests with an
+	// Idempotency-Key, nil to ignore the header
+	Idempotency IdempotencyStore
+	// IdempotencyScope returns the scope of the Idempotency-Key of a
+	// request, the client or tenant making it, nil for global keys
+	IdempotencyScope func(r *http.Request) string
+	// OpenAPI is the document the resources add themselves to, if any
+	OpenAPI *OpenAPI
+	// ValidateSchema validates the bodies of POST and PUT requests
+	// against the JSON Schema of the entity
+	ValidateSchema bool
+}
+
+// Option modifies Options
+type Option func(*Options)
+
+// WithPath sets the path a Resource is mounted on, "/" by default
+func WithPath(path string) Option {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
+
+// WithIDParam sets the name of the URL param holding the entity id,
+// "ID" by default
+func WithIDParam(name string) Option {
+	return func(o *Options) {
+		o.IDParam = name
+	}
+}
+
+// WithOperations restricts a Resource to the given operations,
+// all of them are mounted by default
+func WithOperations(ops Operation) Option {
+	return func(o *Options) {
+		o.Operations = ops
+	}
+}
+
+// WithoutOperations removes the given operations from a Resource
+func WithoutOperations(ops Operation) Option {
+	return func(o *Options) {
+		o.Operations &^= ops
+	}
+}
+
+// WithCursorKey sets the key signing page tokens, it has to be shared
+// by all the instances of a service, a random key is used by default
+func WithCursorKey(key []byte) Option {
+	return func(o *Options) {
+		o.CursorKey = key
+	}
+}
+
+// WithPageSize sets the default and maximum limits of lists,
+// bigger limits are lowered to the maximum
+func WithPageSize(defaultSize, maxSize int) Option {
+	return func(o *Options) {
+		o.DefaultPageSize = defaultSize
+		o.MaxPageSize = maxSize
+	}
+}
+
+// WithListEnvelope wraps lists in a ListEnvelope,
+// instead of rendering them as bare arrays
+func WithListEnvelope() Option {
+	return func(o *Options) {
+		o.ListEnvelope = true
+	}
+}
+
+// WithGoHTTPErrors renders errors as gohttperror.ErrResponse,
+// as before problem details were the default
+func WithGoHTTPErrors() Option {
+	return func(o *Options) {
+		o.GoHTTPErrors = true
+	}
+}
+
+// WithRequiredPreconditions requires If-Match on PUT, PATCH and DELETE,
+// preventing the lost updates of clients not using ETags
+// Bulk patches and deletes, whose items can't have one, are refused
+func WithRequiredPreconditions() Option {
+	return func(o *Options) {
+		o.RequirePreconditions = true
+	}
+}
+
+// WithLogger sets the logger of the handlers, slog.Default() by default
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithOnRenderError sets a hook called when rendering a response fails
+func WithOnRenderError(f func(r *http.Request, err error)) Option {
+	return func(o *Options) {
+		o.OnRenderError = f
+	}
+}
+
+// WithDecodeOptions sets how request bodies are decoded,
+// for the given operations only if any, for all of them otherwise
+func WithDecodeOptions(d DecodeOptions, ops ...Operation) Option {
+	return func(o *Options) {
+		if len(ops) == 0 {
+			o.Decode = d
+			return
+		}
+		if o.OperationDecode == nil {
+			o.OperationDecode = make(map[Operation]DecodeOptions)
+		}
+		for _, op := range ops {
+			for _, single := range op.split() {
+				o.OperationDecode[single] = d
+			}
+		}
+	}
+}
+
+// WithStatus sets the success status of the given operations:
+// 201 or 202 for OpCreate, 200, 202 or 204 for the others
+// The entity is in the body of 200 and 201 responses, unless clients
+// prefer otherwise with Prefer: return=minimal|representation
+// By default, they are 201 Created, 200 OK for OpReplace,
+// 204 No Content for OpPatch and 202 Accepted for OpDelete
+func WithStatus(ops Operation, status int) Option {
+	return func(o *Options) {
+		for _, op := range ops.split() {
+			o.Statuses[op] = status
+		}
+	}
+}
+
+// WithMaxBulkSize sets the maximum number of items of bulk operations,
+// DefaultMaxBulkSize by default
+func WithMaxBulkSize(n int) Option {
+	return func(o *Options) {
+		o.MaxBulkSize = n
+	}
+}
+
+// WithIdempotencyStore sets the store of the responses of POST requests
+// with an Idempotency-Key, an in memory one keeping them for
+// DefaultIdempotencyTTL by default, up to DefaultIdempotencyMaxEntries,
+// nil to ignore the header
+func WithIdempotencyStore(s IdempotencyStore) Option {
+	return func(o *Options) {
+		o.Idempotency = s
+	}
+}
+
+// WithIdempotencyScope scopes the Idempotency-Keys to what scope returns
+// for their request, usually the authenticated client or tenant
+// Keys are global by default: two clients sending the same key and payload
+// get the response of the first one, its body and Location included
+func WithIdempotencyScope(scope func(r *http.Request) string) Option {
+	return func(o *Options) {
+		o.IdempotencyScope = scope
+	}
+}
+
+// WithOpenAPI adds the resource to the OpenAPI document api
+func WithOpenAPI(api *OpenAPI) Option {
+	return func(o *Options) {
+		o.OpenAPI = api
+	}
+}
+
+// WithSchemaValidation validates the bodies of POST and PUT requests
+// against the JSON Schema of the entity, see crud.EntitySchema, before
+// decoding them, invalid ones are answered with 422 Unprocessable Entity
+func WithSchemaValidation() Option {
+	return func(o *Options) {
+		o.ValidateSchema = true
+	}
+}
+
+func newOptions(opts []Option) *Options {
+	o := &Options{
+		Path:       "/",
+		IDParam:    "ID",
+		Operations: OpAll,
+		CursorKey:  defaultCursorKey,
+		Statuses: map[Operation]int{
+			OpCreate:  http.StatusCreated,
+			OpReplace: http.StatusOK,
+			OpPatch:   http.StatusNoContent,
+			OpDelete:  http.StatusAccepted,
+		},
+		MaxBulkSize: DefaultMaxBulkSize,
+		Idempotency: NewMemoryIdempotencyStore(
+			DefaultIdempotencyTTL, DefaultIdempotencyMaxEntries,
+		),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return o
+}
+
+// decodeOptions returns how the request bodies of op are decoded
+func (o *Options) decodeOptions(op Operation) DecodeOptions {
+	if d, ok := o.OperationDecode[op]; ok {
+		return d
+	}
+	return o.Decode
+}
+
+// entitySchema returns the schema the bodies of the entities of cmgr are
+// validated against, nil without WithSchemaValidation
+func (o *Options) entitySchema(cmgr crud.EntityFactory) *crud.Schema {
+	if !o.ValidateSchema {
+		return nil
+	}
+	return crud.EntitySchema(cmgr.NewEmptyEntity())
+}
+
+// renderFailed reports the failure of handler to render its response
+func (o *Options) renderFailed(r *http.Request, handler string, err error) {
+	logRenderFailure(o.Logger, r, handler, err)
+	if o.OnRenderError != nil {
+		o.OnRenderError(r, err)
+	}
+}
+
+// logRenderFailure logs the failure of handler to render its response
+func logRenderFailure(
+	l *slog.Logger,
+	r *http.Request,
+	handler string,
+	err error,
+) {
+	l.ErrorContext(r.Context(), "rest: rendering the response",
+		slog.String("handler", handler),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Any("error", err),
+	)
+}
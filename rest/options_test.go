@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+var errGone = errors.New("client gone")
+
+// goneWriter is a response writer whose client disconnected
+type goneWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w goneWriter) Write([]byte) (int, error) {
+	return 0, errGone
+}
+
+func TestRenderError(t *testing.T) {
+	var logs bytes.Buffer
+	var hookErr error
+
+	h := GETHandler(mock.NewMgr(),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithOnRenderError(func(r *http.Request, err error) {
+			hookErr = err
+		}),
+	)
+
+	req := httptest.NewRequest("GET", "/entity", nil)
+	req = req.WithContext(GetTestContextWithID(req.Context(), xid.New()))
+	h(goneWriter{httptest.NewRecorder()}, req)
+
+	if !errors.Is(hookErr, errGone) {
+		t.Errorf("OnRenderError got %v, want %v", hookErr, errGone)
+	}
+	if !strings.Contains(logs.String(), "handler=GETHandler") {
+		t.Errorf("the render error wasn't logged: %q", logs.String())
+	}
+}
@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/induzo/crud"
+)
+
+// ErrInvalidPageToken is returned for page tokens that were not signed
+// with the cursor key of the handler
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// cursorMACSize is the size of the signature prefixing page tokens
+const cursorMACSize = 16
+
+// defaultCursorKey signs the cursors of handlers without WithCursorKey,
+// it is generated at startup so tokens don't survive a restart
+var defaultCursorKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("rest: generating the cursor key: %v", err))
+	}
+	return key
+}()
+
+// ListEnvelope is the body of lists when WithListEnvelope is set
+type ListEnvelope struct {
+	Data interface{} `json:"data"`
+	Meta ListMeta    `json:"meta"`
+}
+
+// ListMeta is the pagination metadata of a ListEnvelope
+type ListMeta struct {
+	// Total is set when the manager is a crud.Counter
+	Total         *int64 `json:"total,omitempty"`
+	Limit         int    `json:"limit,omitempty"`
+	Offset        int    `json:"offset,omitempty"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+	PrevPageToken string `json:"prev_page_token,omitempty"`
+}
+
+// signCursor turns a manager cursor into an opaque page token
+func signCursor(key []byte, cursor string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(cursor))
+	token := append(mac.Sum(nil)[:cursorMACSize], cursor...)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// verifyPageToken returns the manager cursor of a page token
+func verifyPageToken(key []byte, token string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < cursorMACSize {
+		return "", ErrInvalidPageToken
+	}
+
+	cursor := raw[cursorMACSize:]
+	mac := hmac.New(sha256.New, key)
+	mac.Write(cursor)
+	if !hmac.Equal(raw[:cursorMACSize], mac.Sum(nil)[:cursorMACSize]) {
+		return "", ErrInvalidPageToken
+	}
+	return string(cursor), nil
+}
+
+// paginate replaces the page token of lm by its cursor,
+// and applies the default and maximum page sizes to its limit
+func paginate(lm crud.ListModifiers, o *Options) error {
+	if tokens, ok := lm[crud.ParamPageToken]; ok {
+		if len(tokens) != 1 {
+			return &crud.QueryError{
+				Param:  crud.ParamPageToken,
+				Reason: "must be given once",
+			}
+		}
+		cursor, err := verifyPageToken(o.CursorKey, tokens[0])
+		if err != nil {
+			return &crud.QueryError{
+				Param:  crud.ParamPageToken,
+				Reason: err.Error(),
+			}
+		}
+		lm[crud.ParamPageToken] = []string{cursor}
+	}
+
+	limits, ok := lm[crud.ParamLimit]
+	if !ok {
+		if o.DefaultPageSize > 0 {
+			lm[crud.ParamLimit] = []string{strconv.Itoa(o.DefaultPageSize)}
+		}
+		return nil
+	}
+	if o.MaxPageSize <= 0 {
+		return nil
+	}
+	if len(limits) != 1 {
+		return &crud.QueryError{
+			Param:  crud.ParamLimit,
+			Reason: "must be given once",
+		}
+	}
+	// a limit of 0 would be no limit
+	limit, err := strconv.Atoi(limits[0])
+	if err != nil || limit < 1 {
+		return &crud.QueryError{
+			Param:  crud.ParamLimit,
+			Reason: "must be a positive integer",
+		}
+	}
+	if limit > o.MaxPageSize {
+		lm[crud.ParamLimit] = []string{strconv.Itoa(o.MaxPageSize)}
+	}
+	return nil
+}
+
+// wantCount returns false if the client disabled the count with count=false
+func wantCount(lm crud.ListModifiers) (bool, error) {
+	counts, ok := lm[crud.ParamCount]
+	if !ok {
+		return true, nil
+	}
+	if len(counts) == 1 {
+		if c, err := strconv.ParseBool(counts[0]); err == nil {
+			return c, nil
+		}
+	}
+	return false, &crud.QueryError{
+		Param:  crud.ParamCount,
+		Reason: "must be given once as a boolean",
+	}
+}
+
+// offsetMeta returns the limit and offset of lm, once validated
+func offsetMeta(lm crud.ListModifiers) (limit int, offset int) {
+	if l, ok := lm[crud.ParamLimit]; ok && len(l) == 1 {
+		limit, _ = strconv.Atoi(l[0])
+	}
+	if off, ok := lm[crud.ParamOffset]; ok && len(off) == 1 {
+		offset, _ = strconv.Atoi(off[0])
+	}
+	return limit, offset
+}
+
+// setPageLinks sets the RFC 8288 Link header to the previous
+// and next pages, and their signed page tokens in meta
+func setPageLinks(
+	w http.ResponseWriter,
+	r *http.Request,
+	p *crud.Page,
+	o *Options,
+	meta *ListMeta,
+) {
+	if p.NextCursor != "" {
+		meta.NextPageToken = signCursor(o.CursorKey, p.NextCursor)
+		w.Header().Add("Link", pageLink(r.URL, meta.NextPageToken, "next"))
+	}
+	if p.PrevCursor != "" {
+		meta.PrevPageToken = signCursor(o.CursorKey, p.PrevCursor)
+		w.Header().Add("Link", pageLink(r.URL, meta.PrevPageToken, "prev"))
+	}
+}
+
+func pageLink(u *url.URL, token, rel string) string {
+	q := u.Query()
+	q.Set(crud.ParamPageToken, token)
+	lu := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return fmt.Sprintf(`<%s>; rel="%s"`, lu.String(), rel)
+}
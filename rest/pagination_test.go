@@ -0,0 +1,240 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+)
+
+// pageMgr pages through 10 entities, its cursors are offsets
+type pageMgr struct {
+	*mock.Mgr
+	lastLimit int
+}
+
+func (m *pageMgr) GetList(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (interface{}, error) {
+	q, err := crud.ParseQuery(lm, crud.QuerySpec{})
+	if err != nil {
+		return nil, err
+	}
+	m.lastLimit = q.Limit
+
+	start, _ := strconv.Atoi(q.Cursor)
+	end := start + q.Limit
+	if q.Limit == 0 || end > 10 {
+		end = 10
+	}
+
+	p := &crud.Page{}
+	es := make([]*mock.Entity, 0, end-start)
+	for i := start; i < end; i++ {
+		es = append(es, &mock.Entity{StatusID: i})
+	}
+	p.Items = es
+	if end < 10 {
+		p.NextCursor = strconv.Itoa(end)
+	}
+	if start > 0 {
+		p.PrevCursor = strconv.Itoa(start - q.Limit)
+	}
+	return p, nil
+}
+
+var linkRe = regexp.MustCompile(`<([^>]*)>; rel="(next|prev)"`)
+
+func TestGETListHandlerPagination(t *testing.T) {
+	m := &pageMgr{Mgr: mock.NewMgr()}
+	h := GETListHandler(m,
+		WithPageSize(4, 6),
+		WithListEnvelope(),
+		WithCursorKey([]byte("secret")),
+	)
+
+	// follow the next links through the whole list
+	next := "/entity"
+	var seen, pages int
+	for next != "" {
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest("GET", next, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("page %d: got status %d", pages, rr.Code)
+		}
+
+		env := struct {
+			Data []*mock.Entity `json:"data"`
+			Meta ListMeta       `json:"meta"`
+		}{}
+		if err := json.NewDecoder(rr.Body).Decode(&env); err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, e := range env.Data {
+			if e.StatusID != seen {
+				t.Fatalf("page %d: got entity %d, want %d",
+					pages, e.StatusID, seen)
+			}
+			seen++
+		}
+
+		links := map[string]string{}
+		for _, l := range rr.Header().Values("Link") {
+			if ms := linkRe.FindStringSubmatch(l); ms != nil {
+				links[ms[2]] = ms[1]
+			}
+		}
+		if pages > 0 && links["prev"] == "" {
+			t.Errorf("page %d: no prev link", pages)
+		}
+		if links["next"] != "" {
+			u, _ := url.Parse(links["next"])
+			if got := u.Query().Get("page_token"); got != env.Meta.NextPageToken {
+				t.Errorf("page %d: next link token %q, meta %q",
+					pages, got, env.Meta.NextPageToken)
+			}
+		}
+		next = links["next"]
+		pages++
+	}
+
+	if seen != 10 || pages != 3 {
+		t.Errorf("got %d entities in %d pages, want 10 in 3", seen, pages)
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		wantedStatus int
+		wantedLimit  int
+	}{
+		{
+			name:         "limit over the maximum",
+			url:          "/entity?limit=100",
+			wantedStatus: http.StatusOK,
+			wantedLimit:  6,
+		},
+		{
+			name:         "limit under the maximum",
+			url:          "/entity?limit=5",
+			wantedStatus: http.StatusOK,
+			wantedLimit:  5,
+		},
+		{
+			name:         "zero limit",
+			url:          "/entity?limit=0",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "forged page token",
+			url:          "/entity?page_token=" + signCursor([]byte("pol"), "4"),
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "garbage page token",
+			url:          "/entity?page_token=%25%25",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("GET", tt.url, nil))
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+				return
+			}
+			if tt.wantedLimit != 0 && m.lastLimit != tt.wantedLimit {
+				t.Errorf("manager got limit %d, want %d",
+					m.lastLimit, tt.wantedLimit)
+			}
+		})
+	}
+}
+
+// countMgr is a pageMgr able to count its entities
+type countMgr struct {
+	*pageMgr
+	counted bool
+}
+
+func (m *countMgr) Count(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (int64, error) {
+	m.counted = true
+	return 10, nil
+}
+
+func TestGETListHandlerCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		wantedStatus int
+		wantedCount  string
+		wantedMeta   string
+	}{
+		{
+			name:         "counted",
+			url:          "/entity?limit=2&offset=4",
+			wantedStatus: http.StatusOK,
+			wantedCount:  "10",
+			wantedMeta:   `{"total":10,"limit":2,"offset":4,`,
+		},
+		{
+			name:         "count disabled",
+			url:          "/entity?limit=2&count=false",
+			wantedStatus: http.StatusOK,
+			wantedMeta:   `{"limit":2,"next_page_token":`,
+		},
+		{
+			name:         "bad count",
+			url:          "/entity?count=pol",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &countMgr{pageMgr: &pageMgr{Mgr: mock.NewMgr()}}
+			rr := httptest.NewRecorder()
+			GETListHandler(m, WithListEnvelope())(
+				rr, httptest.NewRequest("GET", tt.url, nil),
+			)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+				return
+			}
+			if got := rr.Header().Get("X-Total-Count"); got != tt.wantedCount {
+				t.Errorf("got X-Total-Count %q, want %q", got, tt.wantedCount)
+			}
+			if m.counted != (tt.wantedCount != "") {
+				t.Errorf("Count called: %v", m.counted)
+			}
+			if tt.wantedMeta == "" {
+				return
+			}
+			env := struct {
+				Meta json.RawMessage `json:"meta"`
+			}{}
+			_ = json.NewDecoder(rr.Body).Decode(&env)
+			if got := string(env.Meta); !strings.HasPrefix(got, tt.wantedMeta) {
+				t.Errorf("got meta %s, want %s...", got, tt.wantedMeta)
+			}
+		})
+	}
+}
@@ -2,28 +2,26 @@ package rest
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi"
-	"github.com/rs/xid"
+	"github.com/induzo/crud"
 )
 
-func parseIDFromRequest(r *http.Request) (xid.ID, error) {
-	idStr := chi.URLParam(r, "ID")
-	ID, errConv := xid.FromString(idStr)
-	if ID.IsNil() || errConv != nil {
-		return xid.NilID(),
-			fmt.Errorf("parseIDFromRequest(%s): %v", idStr, errConv)
-	}
-
-	return ID, nil
+// parseIDFromRequest parses the URL param named param
+// with the id parser of cmgr, see crud.ParseID
+func parseIDFromRequest(
+	r *http.Request,
+	cmgr interface{},
+	param string,
+) (crud.ID, error) {
+	return crud.ParseID(cmgr, chi.URLParam(r, param))
 }
 
-// GetTestContextWithID will return a context with an xid as chi URL Params
+// GetTestContextWithID will return a context with an id as chi URL Params
 func GetTestContextWithID(
 	ctx context.Context,
-	ID xid.ID,
+	ID crud.ID,
 ) context.Context {
 
 	// Set the URL param
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/induzo/crud"
+	"github.com/rs/xid"
+	"github.com/segmentio/ksuid"
+)
+
+type idParserFunc func(string) (crud.ID, error)
+
+func (f idParserFunc) ParseID(s string) (crud.ID, error) { return f(s) }
+
+func TestParseIDFromRequest(t *testing.T) {
+	parseUUID := idParserFunc(func(s string) (crud.ID, error) {
+		return crud.ParseUUID(s)
+	})
+	parseKSUID := idParserFunc(func(s string) (crud.ID, error) {
+		return crud.ParseKSUID(s)
+	})
+	parseInt64 := idParserFunc(func(s string) (crud.ID, error) {
+		return crud.ParseInt64ID(s)
+	})
+
+	tests := []struct {
+		name    string
+		mgr     interface{}
+		id      crud.ID
+		wantErr bool
+	}{
+		{
+			name: "default xid",
+			mgr:  struct{}{},
+			id:   xid.New(),
+		},
+		{
+			name:    "nil xid",
+			mgr:     struct{}{},
+			id:      xid.NilID(),
+			wantErr: true,
+		},
+		{
+			name: "uuid",
+			mgr:  parseUUID,
+			id:   uuid.New(),
+		},
+		{
+			name:    "xid with an uuid parser",
+			mgr:     parseUUID,
+			id:      xid.New(),
+			wantErr: true,
+		},
+		{
+			name: "ksuid",
+			mgr:  parseKSUID,
+			id:   ksuid.New(),
+		},
+		{
+			name: "int64",
+			mgr:  parseInt64,
+			id:   crud.Int64ID(42),
+		},
+		{
+			name:    "negative int64",
+			mgr:     parseInt64,
+			id:      crud.Int64ID(-42),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", `http://dummy/entity`, nil)
+			req = req.WithContext(GetTestContextWithID(req.Context(), tt.id))
+
+			got, err := parseIDFromRequest(req, tt.mgr, "ID")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseIDFromRequest() error = %v, wantErr %v",
+					err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.id {
+				t.Errorf("parseIDFromRequest() = %v, want %v", got, tt.id)
+			}
+		})
+	}
+}
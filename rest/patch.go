@@ -0,0 +1,259 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+const (
+	// MergePatchContentType is the media type of RFC 7386 merge patches
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of RFC 6902 JSON patches
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+// errUnsupportedPatch is returned for a patch format cmgr cannot apply
+var errUnsupportedPatch = &gohttperror.ErrResponse{
+	HTTPStatusCode: http.StatusUnsupportedMediaType,
+	StatusText:     "Unsupported media type",
+}
+
+// canGetAndUpdate returns true if cmgr can apply patches
+// to the entity returned by Get and save it with Update
+func canGetAndUpdate(cmgr interface{}) bool {
+	_, okG := cmgr.(crud.Getter)
+	_, okU := cmgr.(crud.Updater)
+	return okG && okU
+}
+
+// supportsMergePatch returns true if cmgr can apply merge patches,
+// itself or through Get and Update
+func supportsMergePatch(cmgr interface{}) bool {
+	_, ok := cmgr.(crud.Patcher)
+	return ok || canGetAndUpdate(cmgr)
+}
+
+// supportsJSONPatch returns true if cmgr can apply JSON patches,
+// itself or through Get and Update
+func supportsJSONPatch(cmgr interface{}) bool {
+	_, ok := cmgr.(crud.JSONPatcher)
+	return ok || canGetAndUpdate(cmgr)
+}
+
+// acceptPatch returns the Accept-Patch header value of cmgr
+func acceptPatch(cmgr interface{}) string {
+	var types []string
+	if supportsMergePatch(cmgr) {
+		types = append(types, MergePatchContentType)
+	}
+	if supportsJSONPatch(cmgr) {
+		types = append(types, JSONPatchContentType)
+	}
+	return strings.Join(types, ", ")
+}
+
+// patchType returns the patch media type of the request
+// if cmgr supports it, an empty string otherwise
+func patchType(r *http.Request, cmgr interface{}) string {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case err != nil:
+		return ""
+	case mt == MergePatchContentType && supportsMergePatch(cmgr),
+		mt == JSONPatchContentType && supportsJSONPatch(cmgr):
+		return mt
+	default:
+		return ""
+	}
+}
+
+// applyMergePatch applies the merge patch body to the entity ID
+// with the crud.Patcher of cmgr, or to the current entity that is then
+// saved with Update, in which case the updated entity is returned
+func applyMergePatch(
+	ctx context.Context,
+	cmgr interface{},
+	ID crud.ID,
+	body []byte,
+	d DecodeOptions,
+) (interface{}, error) {
+	updates, err := decodeMergePatch(cmgr, body, d)
+	if err != nil {
+		return nil, err
+	}
+	return mergePatch(ctx, cmgr, ID, updates, body)
+}
+
+// decodeMergePatch decodes the merge patch body,
+// checking its fields against the entities of cmgr
+func decodeMergePatch(
+	cmgr interface{},
+	body []byte,
+	d DecodeOptions,
+) (crud.PartialUpdateData, error) {
+	var updates crud.PartialUpdateData
+	err := decodeJSON(body, &updates, d)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", crud.ErrInvalidPatch, err)
+	case updates == nil:
+		return nil, fmt.Errorf("%w: not an object", crud.ErrInvalidPatch)
+	}
+	if f, ok := cmgr.(crud.EntityFactory); ok {
+		if err := updates.Check(f.NewEmptyEntity()); err != nil {
+			return nil, err
+		}
+	}
+	return updates, nil
+}
+
+// mergePatch applies updates, decoded from body, to the entity ID
+// the way applyMergePatch does
+func mergePatch(
+	ctx context.Context,
+	cmgr interface{},
+	ID crud.ID,
+	updates crud.PartialUpdateData,
+	body []byte,
+) (interface{}, error) {
+	if p, ok := cmgr.(crud.Patcher); ok {
+		if err := validateMergePatch(ctx, cmgr, ID, updates); err != nil {
+			return nil, err
+		}
+		return nil, p.PartialUpdate(ctx, ID, updates, bytes.NewReader(body))
+	}
+
+	cur, err := cmgr.(crud.Getter).Get(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	u := cmgr.(crud.Updater)
+	ent := u.NewEmptyEntity()
+	if err := updates.ApplyTo(cur, ent); err != nil {
+		return nil, err
+	}
+	if err := crud.Validate(ent); err != nil {
+		return nil, err
+	}
+	return u.Update(ctx, ID, ent, bytes.NewReader(body))
+}
+
+// validateMergePatch validates the entity ID patched with updates
+// before a crud.Patcher applies them, if its entities have validations
+// Without a crud.Getter, updates are applied to an empty entity,
+// and only the errors of the patched fields are kept
+func validateMergePatch(
+	ctx context.Context,
+	cmgr interface{},
+	ID crud.ID,
+	updates crud.PartialUpdateData,
+) error {
+	f, ok := cmgr.(crud.EntityFactory)
+	if !ok || !crud.HasValidation(f.NewEmptyEntity()) {
+		return nil
+	}
+
+	cur := f.NewEmptyEntity()
+	g, full := cmgr.(crud.Getter)
+	if full {
+		var err error
+		if cur, err = g.Get(ctx, ID); err != nil {
+			return err
+		}
+	}
+	ent := f.NewEmptyEntity()
+	if err := updates.ApplyTo(cur, ent); err != nil {
+		return err
+	}
+
+	err := crud.Validate(ent)
+	var ve *crud.ValidationError
+	if full || !errors.As(err, &ve) {
+		return err
+	}
+	paths := mergePatchPaths(updates, "")
+	var fes []crud.FieldError
+	for _, fe := range ve.Errors {
+		for _, p := range paths {
+			if fe.Pointer == p || strings.HasPrefix(fe.Pointer, p+"/") {
+				fes = append(fes, fe)
+				break
+			}
+		}
+	}
+	if len(fes) == 0 {
+		return nil
+	}
+	return &crud.ValidationError{Errors: fes}
+}
+
+// mergePatchPaths returns the JSON pointers of the fields set by a
+// merge patch, the leaves of its nested objects
+func mergePatchPaths(patch map[string]interface{}, prefix string) []string {
+	var paths []string
+	for k, v := range patch {
+		p := prefix + crud.Pointer{k}.String()
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			paths = append(paths, mergePatchPaths(sub, p)...)
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// applyJSONPatch applies the JSON patch body to the entity ID
+// with the crud.JSONPatcher of cmgr, or to the current entity that is
+// then saved with Update, and returns the updated entity
+func applyJSONPatch(
+	ctx context.Context,
+	cmgr interface{},
+	ID crud.ID,
+	body []byte,
+	d DecodeOptions,
+) (interface{}, error) {
+	// RFC 6902 ignores the unknown members of operations
+	d.DisallowUnknownFields = false
+	var p crud.JSONPatch
+	if err := decodeJSON(body, &p, d); err != nil {
+		return nil, fmt.Errorf("%w: %v", crud.ErrInvalidPatch, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	if jp, ok := cmgr.(crud.JSONPatcher); ok {
+		return jp.JSONPatch(ctx, ID, p, bytes.NewReader(body))
+	}
+
+	cur, err := cmgr.(crud.Getter).Get(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(cur)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := p.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+	u := cmgr.(crud.Updater)
+	ent := u.NewEmptyEntity()
+	if err := json.Unmarshal(patched, ent); err != nil {
+		return nil, fmt.Errorf("%w: %v", crud.ErrUnprocessableEntity, err)
+	}
+	if err := crud.Validate(ent); err != nil {
+		return nil, err
+	}
+	return u.Update(ctx, ID, ent, bytes.NewReader(patched))
+}
@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+)
+
+// mergeOnlyMgr is a mock manager that only applies merge patches
+type mergeOnlyMgr struct {
+	crud.Patcher
+}
+
+// getUpdateMgr is a mock manager without patch support of its own
+type getUpdateMgr struct {
+	crud.Getter
+	crud.Updater
+}
+
+// jsonPatchMgr is a mock manager applying JSON patches itself
+type jsonPatchMgr struct {
+	*mock.Mgr
+	patch crud.JSONPatch
+}
+
+func (m *jsonPatchMgr) JSONPatch(
+	ctx context.Context,
+	id crud.ID,
+	p crud.JSONPatch,
+	pl io.Reader,
+) (interface{}, error) {
+	m.patch = p
+	e, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ent := m.NewEmptyEntity()
+	if err := p.ApplyTo(e, ent); err != nil {
+		return nil, err
+	}
+	return m.Update(ctx, id, ent, pl)
+}
+
+func TestPATCHHandlerJSONPatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		mgr          string
+		contentType  string
+		body         string
+		wantedStatus int
+		wantedAccept string
+		wantedValue  int
+	}{
+		{
+			name:         "merge patch",
+			contentType:  MergePatchContentType,
+			body:         `{"status_id": 2}`,
+			wantedStatus: http.StatusNoContent,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  2,
+		},
+		{
+			name:         "merge patch through get and update",
+			mgr:          "get",
+			contentType:  MergePatchContentType,
+			body:         `{"status_id": null}`,
+			wantedStatus: http.StatusNoContent,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  0,
+		},
+		{
+			name:         "merge patch of an unknown field",
+			contentType:  MergePatchContentType,
+			body:         `{"status_id": 2, "status": 2}`,
+			wantedStatus: http.StatusBadRequest,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "merge patch not an object",
+			contentType:  MergePatchContentType,
+			body:         `null`,
+			wantedStatus: http.StatusBadRequest,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "plain json",
+			contentType:  "application/json",
+			body:         `{"status_id": 2}`,
+			wantedStatus: http.StatusUnsupportedMediaType,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:        "json patch through get and update",
+			contentType: JSONPatchContentType,
+			body: `[{"op": "test", "path": "/status_id", "value": 1},` +
+				`{"op": "replace", "path": "/status_id", "value": 3}]`,
+			wantedStatus: http.StatusNoContent,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  3,
+		},
+		{
+			name:         "json patch through the manager",
+			mgr:          "json",
+			contentType:  JSONPatchContentType + "; charset=utf-8",
+			body:         `[{"op": "add", "path": "/status_id", "value": 4}]`,
+			wantedStatus: http.StatusNoContent,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  4,
+		},
+		{
+			name:         "failed test",
+			contentType:  JSONPatchContentType,
+			body:         `[{"op": "test", "path": "/status_id", "value": 2}]`,
+			wantedStatus: http.StatusConflict,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "invalid operation",
+			contentType:  JSONPatchContentType,
+			body:         `[{"op": "merge", "path": "/status_id"}]`,
+			wantedStatus: http.StatusBadRequest,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "not a patch document",
+			contentType:  JSONPatchContentType,
+			body:         `{"status_id": 2}`,
+			wantedStatus: http.StatusBadRequest,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "invalid patched entity",
+			contentType:  JSONPatchContentType,
+			body:         `[{"op": "replace", "path": "/status_id", "value": "a"}]`,
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedAccept: MergePatchContentType + ", " + JSONPatchContentType,
+			wantedValue:  1,
+		},
+		{
+			name:         "json patch unsupported",
+			mgr:          "merge",
+			contentType:  JSONPatchContentType,
+			body:         `[{"op": "remove", "path": "/status_id"}]`,
+			wantedStatus: http.StatusUnsupportedMediaType,
+			wantedAccept: MergePatchContentType,
+			wantedValue:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			ec, _ := m.Create(
+				context.Background(), &mock.Entity{StatusID: 1}, nil,
+			)
+			id := ec.(*mock.Entity).ID
+
+			var cmgr interface{} = m
+			jm := &jsonPatchMgr{Mgr: m}
+			switch tt.mgr {
+			case "json":
+				cmgr = jm
+			case "merge":
+				cmgr = &mergeOnlyMgr{Patcher: m}
+			case "get":
+				cmgr = &getUpdateMgr{Getter: m, Updater: m}
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("PATCH", "/entity",
+				bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(GetTestContextWithID(req.Context(), id))
+
+			PATCHHandler(cmgr)(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if got := rr.Header().Get("Accept-Patch"); got != tt.wantedAccept {
+				t.Errorf("handler returned Accept-Patch %q, want %q",
+					got, tt.wantedAccept)
+			}
+			if got := m.EntityList[id].StatusID; got != tt.wantedValue {
+				t.Errorf("entity has status %d, want %d", got, tt.wantedValue)
+			}
+			if tt.mgr == "json" && len(jm.patch) != 1 {
+				t.Errorf("manager got patch %v", jm.patch)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/induzo/crud"
+)
+
+// preferReturn returns the return preference of the Prefer headers
+// of r, minimal or representation, if any
+func preferReturn(r *http.Request) string {
+	for _, h := range r.Header.Values("Prefer") {
+		for _, p := range strings.Split(h, ",") {
+			p, _, _ = strings.Cut(p, ";")
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "return") {
+				continue
+			}
+			switch v := strings.Trim(strings.TrimSpace(value), `"`); v {
+			case "minimal", "representation":
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// success returns the status of the successful op, and if its response
+// has the entity as body, following the Prefer header of the request
+// canRepresent is false if the entity cannot be rendered
+func (o *Options) success(
+	w http.ResponseWriter,
+	r *http.Request,
+	op Operation,
+	canRepresent bool,
+) (int, bool) {
+	status := o.Statuses[op]
+	representation := status == http.StatusOK ||
+		status == http.StatusCreated
+	switch pref := preferReturn(r); {
+	case pref == "minimal", pref == "representation" && canRepresent:
+		representation = pref == "representation"
+		w.Header().Set("Preference-Applied", "return="+pref)
+	}
+	if !canRepresent {
+		representation = false
+	}
+
+	switch {
+	case representation && status == http.StatusNoContent:
+		status = http.StatusOK
+	case !representation && status == http.StatusOK:
+		status = http.StatusNoContent
+	}
+	return status, representation
+}
+
+// setLocation sets the Location header of the entity e
+// created by a POST on the collection of r
+func setLocation(w http.ResponseWriter, r *http.Request, e interface{}) {
+	id, ok := crud.EntityID(e)
+	if !ok {
+		return
+	}
+	w.Header().Set("Location",
+		strings.TrimSuffix(r.URL.Path, "/")+"/"+url.PathEscape(id))
+}
@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+)
+
+// deleteOnlyMgr is a mock manager unable to get its entities
+type deleteOnlyMgr struct {
+	crud.Deleter
+}
+
+func TestSuccessStatuses(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		opts           []Option
+		prefer         string
+		deleteOnly     bool
+		wantedStatus   int
+		wantedBody     bool
+		wantedApplied  string
+		wantedLocation bool
+	}{
+		{
+			name:           "POST",
+			method:         "POST",
+			wantedStatus:   http.StatusCreated,
+			wantedBody:     true,
+			wantedLocation: true,
+		},
+		{
+			name:           "POST minimal",
+			method:         "POST",
+			prefer:         "return=minimal",
+			wantedStatus:   http.StatusCreated,
+			wantedApplied:  "return=minimal",
+			wantedLocation: true,
+		},
+		{
+			name:          "PUT minimal",
+			method:        "PUT",
+			prefer:        `respond-async, return="minimal"; x=1`,
+			wantedStatus:  http.StatusNoContent,
+			wantedApplied: "return=minimal",
+		},
+		{
+			name:         "PATCH",
+			method:       "PATCH",
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:          "PATCH representation",
+			method:        "PATCH",
+			prefer:        "return=representation",
+			wantedStatus:  http.StatusOK,
+			wantedBody:    true,
+			wantedApplied: "return=representation",
+		},
+		{
+			name:         "PATCH with 200",
+			method:       "PATCH",
+			opts:         []Option{WithStatus(OpPatch|OpDelete, http.StatusOK)},
+			wantedStatus: http.StatusOK,
+			wantedBody:   true,
+		},
+		{
+			name:         "DELETE",
+			method:       "DELETE",
+			wantedStatus: http.StatusAccepted,
+		},
+		{
+			name:         "DELETE with 204",
+			method:       "DELETE",
+			opts:         []Option{WithStatus(OpDelete, http.StatusNoContent)},
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "DELETE with 200",
+			method:       "DELETE",
+			opts:         []Option{WithStatus(OpPatch|OpDelete, http.StatusOK)},
+			wantedStatus: http.StatusOK,
+			wantedBody:   true,
+		},
+		{
+			name:         "DELETE with 200 without Get",
+			method:       "DELETE",
+			opts:         []Option{WithStatus(OpDelete, http.StatusOK)},
+			deleteOnly:   true,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "DELETE representation without Get",
+			method:       "DELETE",
+			prefer:       "return=representation",
+			deleteOnly:   true,
+			wantedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMgr()
+			ec, _ := m.Create(
+				context.Background(), &mock.Entity{StatusID: 1}, nil,
+			)
+			id := ec.(*mock.Entity).ID
+
+			var deleter crud.Deleter = m
+			if tt.deleteOnly {
+				deleter = &deleteOnlyMgr{Deleter: m}
+			}
+			h := map[string]func(http.ResponseWriter, *http.Request){
+				"POST":   POSTHandler(m, tt.opts...),
+				"PUT":    PUTHandler(m, tt.opts...),
+				"PATCH":  PATCHHandler(m, tt.opts...),
+				"DELETE": DELETEHandler(deleter, tt.opts...),
+			}[tt.method]
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/e/",
+				bytes.NewBufferString(`{"status_id": 2}`))
+			req.Header.Set("Content-Type", MergePatchContentType)
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			req = req.WithContext(GetTestContextWithID(req.Context(), id))
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if got := rr.Header().Get("Preference-Applied"); got != tt.wantedApplied {
+				t.Errorf("handler returned Preference-Applied %q, want %q",
+					got, tt.wantedApplied)
+			}
+			if (rr.Body.Len() > 0) != tt.wantedBody {
+				t.Errorf("handler returned body %q", rr.Body.String())
+			}
+			if tt.wantedBody {
+				var e mock.Entity
+				if err := json.NewDecoder(rr.Body).Decode(&e); err != nil ||
+					e.ID.IsNil() {
+					t.Errorf("handler returned no entity: %v", err)
+				}
+			}
+			loc := rr.Header().Get("Location")
+			if tt.wantedLocation && (len(loc) != len("/e/")+20 || loc[:3] != "/e/") {
+				t.Errorf("handler returned Location %q", loc)
+			}
+			if !tt.wantedLocation && loc != "" {
+				t.Errorf("handler returned Location %q", loc)
+			}
+		})
+	}
+}
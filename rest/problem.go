@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// renderError writes e as RFC 7807 problem details,
+// or as a gohttperror.ErrResponse with WithGoHTTPErrors
+func renderError(
+	w http.ResponseWriter,
+	r *http.Request,
+	o *Options,
+	e *gohttperror.ErrResponse,
+) error {
+	if o.GoHTTPErrors {
+		return render.Render(w, r, e)
+	}
+
+	// keep the side effects of gohttperror, the request error for gohttpmw
+	if err := e.Render(w, r); err != nil {
+		return err
+	}
+
+	p := NewProblem(r, e)
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_, err = w.Write(b)
+	return err
+}
+
+// NewProblem converts e to problem details,
+// using the crud.Problem it wraps if any
+// The field errors of a crud.ValidationError are listed in errors
+// The detail is the ErrorText of e, or the message of the error of the
+// crud package it wraps, for client errors only, other errors are not
+// disclosed unless set by a crud.Problem
+func NewProblem(r *http.Request, e *gohttperror.ErrResponse) *crud.Problem {
+	p := &crud.Problem{}
+	var cp *crud.Problem
+	if errors.As(e.Err, &cp) {
+		*p = *cp
+	}
+
+	if p.Status == 0 {
+		p.Status = e.HTTPStatusCode
+	}
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Detail == "" && cp == nil {
+		p.Detail = e.ErrorText
+		if p.Detail == "" && p.Status < 500 {
+			p.Detail = crudDetail(e.Err)
+		}
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	var ve *crud.ValidationError
+	if errors.As(e.Err, &ve) {
+		ext := make(map[string]interface{}, len(p.Extensions)+1)
+		for k, v := range p.Extensions {
+			ext[k] = v
+		}
+		ext["errors"] = ve.Errors
+		p.Extensions = ext
+	}
+
+	return p
+}
+
+// crudDetail returns the message of err if it is an error of the
+// crud package, about the request, empty otherwise
+func crudDetail(err error) string {
+	var qe *crud.QueryError
+	var ve *crud.ValidationError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &qe):
+		return qe.Error()
+	case errors.As(err, &ve):
+		return ve.Error()
+	case errors.Is(err, crud.ErrInvalidID),
+		errors.Is(err, crud.ErrInvalidPatch),
+		errors.Is(err, crud.ErrPatchConflict),
+		errors.Is(err, crud.ErrUnprocessableEntity):
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+// problemMgr is a mock manager failing Get with err
+type problemMgr struct {
+	*mock.Mgr
+	err error
+}
+
+func (m problemMgr) Get(ctx context.Context, id crud.ID) (interface{}, error) {
+	return nil, m.err
+}
+
+func TestProblemErrors(t *testing.T) {
+	id := xid.New()
+
+	tests := []struct {
+		name              string
+		err               error
+		opts              []Option
+		id                crud.ID
+		wantedStatus      int
+		wantedContentType string
+		wantedBody        map[string]interface{}
+	}{
+		{
+			name: "manager problem",
+			err: fmt.Errorf("wrapped: %w", &crud.Problem{
+				Type:   "https://induzo.com/problems/out-of-stock",
+				Title:  "Out of stock",
+				Status: http.StatusConflict,
+				Detail: "no more entity",
+				Extensions: map[string]interface{}{
+					"stock": 0,
+				},
+			}),
+			id:                id,
+			wantedStatus:      http.StatusConflict,
+			wantedContentType: ProblemContentType,
+			wantedBody: map[string]interface{}{
+				"type":     "https://induzo.com/problems/out-of-stock",
+				"title":    "Out of stock",
+				"status":   float64(http.StatusConflict),
+				"detail":   "no more entity",
+				"instance": "/entity/" + id.String(),
+				"stock":    float64(0),
+			},
+		},
+		{
+			name:              "mapped error",
+			err:               mock.ErrNotFound,
+			id:                id,
+			wantedStatus:      http.StatusNotFound,
+			wantedContentType: ProblemContentType,
+			wantedBody: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   float64(http.StatusNotFound),
+				"instance": "/entity/" + id.String(),
+			},
+		},
+		{
+			name:              "internal error details are not disclosed",
+			err:               fmt.Errorf("db password is pol"),
+			id:                id,
+			wantedStatus:      http.StatusInternalServerError,
+			wantedContentType: ProblemContentType,
+			wantedBody: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   float64(http.StatusInternalServerError),
+				"instance": "/entity/" + id.String(),
+			},
+		},
+		{
+			name:              "client error details are not disclosed",
+			err:               mock.ErrForbidden,
+			id:                id,
+			wantedStatus:      http.StatusForbidden,
+			wantedContentType: ProblemContentType,
+			wantedBody: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Forbidden",
+				"status":   float64(http.StatusForbidden),
+				"instance": "/entity/" + id.String(),
+			},
+		},
+		{
+			name:              "bad id",
+			id:                xid.NilID(),
+			wantedStatus:      http.StatusBadRequest,
+			wantedContentType: ProblemContentType,
+			wantedBody: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Bad Request",
+				"status":   float64(http.StatusBadRequest),
+				"detail":   "invalid id \"00000000000000000000\"",
+				"instance": "/entity/00000000000000000000",
+			},
+		},
+		{
+			name:              "gohttperror",
+			err:               mock.ErrForbidden,
+			opts:              []Option{WithGoHTTPErrors()},
+			id:                id,
+			wantedStatus:      http.StatusForbidden,
+			wantedContentType: "application/json; charset=utf-8",
+			wantedBody: map[string]interface{}{
+				"status": "Forbidden",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := problemMgr{Mgr: mock.NewMgr(), err: tt.err}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/entity/"+tt.id.String(), nil)
+			req = req.WithContext(GetTestContextWithID(req.Context(), tt.id))
+
+			GETHandler(m, tt.opts...)(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != tt.wantedContentType {
+				t.Errorf("handler returned wrong content type: got %q want %q",
+					ct, tt.wantedContentType)
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Errorf("handler returned a bad body: %v", err)
+			}
+			if !reflect.DeepEqual(body, tt.wantedBody) {
+				t.Errorf("handler returned %v, want %v", body, tt.wantedBody)
+			}
+		})
+	}
+}
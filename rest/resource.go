@@ -0,0 +1,285 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// Resource mounts all the REST handlers of a manager at once
+type Resource struct {
+	cmgr interface{}
+	opts []Option
+	o    *Options
+	ops  Operation
+	mux  *chi.Mux
+}
+
+// NewResource creates the resource of the manager cmgr,
+// the options apply to the resource and to all its handlers
+// cmgr is usually a crud.MgrI, but it only needs to implement the
+// interfaces it is made of for the operations it supports,
+// crud.Getter for OpGet, crud.Lister for OpList...
+// NewResource panics if cmgr supports none of the operations
+func NewResource(cmgr interface{}, opts ...Option) *Resource {
+	res := &Resource{cmgr: cmgr, o: newOptions(opts)}
+	// the handlers share the idempotency store, the default one included
+	res.opts = append(opts[:len(opts):len(opts)],
+		WithIdempotencyStore(res.o.Idempotency))
+
+	res.ops = res.o.Operations & SupportedOperations(cmgr)
+	if res.ops == 0 {
+		panic(fmt.Sprintf("rest: %T supports none of the operations", cmgr))
+	}
+
+	res.mux = chi.NewRouter()
+	res.Mount(res.mux)
+	if res.o.OpenAPI != nil {
+		res.o.OpenAPI.Add(res)
+	}
+
+	return res
+}
+
+// SupportedOperations returns the operations cmgr implements
+func SupportedOperations(cmgr interface{}) Operation {
+	var ops Operation
+	if _, ok := cmgr.(crud.Lister); ok {
+		ops |= OpList
+	}
+	if _, ok := cmgr.(crud.Creator); ok {
+		ops |= OpCreate
+	}
+	if _, ok := cmgr.(crud.Getter); ok {
+		ops |= OpGet
+	}
+	if _, ok := cmgr.(crud.Updater); ok {
+		ops |= OpReplace
+	}
+	if _, ok := cmgr.(crud.Patcher); ok || supportsJSONPatch(cmgr) {
+		ops |= OpPatch
+	}
+	if _, ok := cmgr.(crud.Deleter); ok {
+		ops |= OpDelete
+	}
+	if _, ok := cmgr.(crud.BulkCreator); ok || ops.Has(OpCreate) {
+		ops |= OpBulkCreate
+	}
+	if _, ok := cmgr.(crud.BulkPatcher); ok || supportsMergePatch(cmgr) {
+		ops |= OpBulkPatch
+	}
+	if _, ok := cmgr.(crud.BulkDeleter); ok || ops.Has(OpDelete) {
+		ops |= OpBulkDelete
+	}
+	if _, ok := cmgr.(crud.EntityFactory); ok {
+		ops |= OpSchema
+	}
+	return ops
+}
+
+// Mount is a shortcut for NewResource(cmgr, opts...).Mount(r)
+func Mount(r chi.Router, cmgr interface{}, opts ...Option) {
+	NewResource(cmgr, opts...).Mount(r)
+}
+
+// Mount registers the routes of the resource on r, under its path:
+//
+//	GET    /path       list
+//	POST   /path       create, or bulk create with an array
+//	PATCH  /path       bulk patch
+//	DELETE /path       bulk delete, ?ids=
+//	GET    /path/{ID}  get
+//	PUT    /path/{ID}  replace
+//	PATCH  /path/{ID}  patch
+//	DELETE /path/{ID}  delete
+//	GET    /path/_schema  JSON Schema of the entities
+//
+// along with HEAD for GET, and OPTIONS, on both paths
+// The methods of the operations that are not enabled
+// are answered with 405 Method Not Allowed and an Allow header
+func (res *Resource) Mount(r chi.Router) {
+	if res.o.Path == "" || res.o.Path == "/" {
+		res.routes(r)
+		return
+	}
+	r.Route(res.o.Path, res.routes)
+}
+
+// ServeHTTP lets the resource be used as a plain http.Handler
+func (res *Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res.mux.ServeHTTP(w, r)
+}
+
+// Operations returns the operations the resource mounts
+func (res *Resource) Operations() Operation {
+	return res.ops
+}
+
+// route is a method of a pattern,
+// enabled if any of the operations of op is
+type route struct {
+	method string
+	op     Operation
+}
+
+var (
+	collectionRoutes = []route{
+		{http.MethodGet, OpList},
+		{http.MethodHead, OpList},
+		{http.MethodPost, OpCreate | OpBulkCreate},
+		{http.MethodPatch, OpBulkPatch},
+		{http.MethodDelete, OpBulkDelete},
+	}
+	entityRoutes = []route{
+		{http.MethodGet, OpGet},
+		{http.MethodHead, OpGet},
+		{http.MethodPut, OpReplace},
+		{http.MethodPatch, OpPatch},
+		{http.MethodDelete, OpDelete},
+	}
+	schemaRoutes = []route{
+		{http.MethodGet, OpSchema},
+		{http.MethodHead, OpSchema},
+	}
+)
+
+// routeHandlers builds the handlers of the methods of a pattern
+type routeHandlers map[string]func() http.HandlerFunc
+
+// allowedMethods returns the methods of the routes enabled by ops,
+// along with OPTIONS, nil if none is
+func allowedMethods(routes []route, ops Operation) []string {
+	var allowed []string
+	for _, rt := range routes {
+		if ops&rt.op != 0 {
+			allowed = append(allowed, rt.method)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	return append(allowed, http.MethodOptions)
+}
+
+func (res *Resource) routes(r chi.Router) {
+	res.pattern(r, "/", collectionRoutes, routeHandlers{
+		http.MethodGet: func() http.HandlerFunc {
+			return GETListHandler(res.cmgr.(crud.Lister), res.opts...)
+		},
+		http.MethodHead: func() http.HandlerFunc {
+			return HEADListHandler(res.cmgr, res.opts...)
+		},
+		http.MethodPost: res.createHandler,
+		http.MethodPatch: func() http.HandlerFunc {
+			return BulkPATCHHandler(res.cmgr, res.opts...)
+		},
+		http.MethodDelete: func() http.HandlerFunc {
+			return BulkDELETEHandler(res.cmgr, res.opts...)
+		},
+		http.MethodOptions: func() http.HandlerFunc {
+			return OPTIONSListHandler(res.cmgr, res.opts...)
+		},
+	})
+	res.pattern(r, "/{"+res.o.IDParam+"}", entityRoutes, routeHandlers{
+		http.MethodGet: func() http.HandlerFunc {
+			return GETHandler(res.cmgr.(crud.Getter), res.opts...)
+		},
+		http.MethodHead: func() http.HandlerFunc {
+			return HEADHandler(res.cmgr, res.opts...)
+		},
+		http.MethodPut: func() http.HandlerFunc {
+			return PUTHandler(res.cmgr.(crud.Updater), res.opts...)
+		},
+		http.MethodPatch: func() http.HandlerFunc {
+			return PATCHHandler(res.cmgr, res.opts...)
+		},
+		http.MethodDelete: func() http.HandlerFunc {
+			return DELETEHandler(res.cmgr.(crud.Deleter), res.opts...)
+		},
+		http.MethodOptions: func() http.HandlerFunc {
+			return OPTIONSHandler(res.cmgr, res.opts...)
+		},
+	})
+	res.pattern(r, SchemaPath, schemaRoutes, routeHandlers{
+		http.MethodGet: res.schemaHandler,
+		http.MethodHead: func() http.HandlerFunc {
+			get := res.schemaHandler()
+			return func(w http.ResponseWriter, r *http.Request) {
+				get(headWriter{w}, r)
+			}
+		},
+		http.MethodOptions: func() http.HandlerFunc {
+			return optionsHandler(allowedMethods(schemaRoutes, res.ops), "")
+		},
+	})
+}
+
+func (res *Resource) schemaHandler() http.HandlerFunc {
+	return SchemaHandler(res.cmgr.(crud.EntityFactory), res.opts...)
+}
+
+// createHandler returns the handler of POST on the collection,
+// bulk creating the entities of arrays
+func (res *Resource) createHandler() http.HandlerFunc {
+	var single, bulk http.HandlerFunc
+	if res.ops.Has(OpCreate) {
+		single = POSTHandler(res.cmgr.(crud.Creator), res.opts...)
+	}
+	if res.ops.Has(OpBulkCreate) {
+		bulk = BulkPOSTHandler(res.cmgr.(crud.EntityFactory), res.opts...)
+	}
+	switch {
+	case single == nil:
+		return bulk
+	case bulk == nil:
+		return single
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isArrayBody(r) {
+			bulk(w, r)
+			return
+		}
+		single(w, r)
+	}
+}
+
+// pattern registers the routes of pattern with their handlers,
+// the methods of disabled routes get a 405 handler
+func (res *Resource) pattern(
+	r chi.Router,
+	pattern string,
+	routes []route,
+	handlers routeHandlers,
+) {
+	allowed := allowedMethods(routes, res.ops)
+	if len(allowed) == 0 {
+		return
+	}
+
+	notAllowed := methodNotAllowedHandler(allowed, res.o)
+	for _, rt := range routes {
+		if res.ops&rt.op != 0 {
+			r.Method(rt.method, pattern, handlers[rt.method]())
+			continue
+		}
+		r.Method(rt.method, pattern, notAllowed)
+	}
+	r.Method(http.MethodOptions, pattern, handlers[http.MethodOptions]())
+}
+
+func methodNotAllowedHandler(allowed []string, o *Options) http.HandlerFunc {
+	allow := strings.Join(allowed, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if err := renderError(w, r, o, &gohttperror.ErrResponse{
+			HTTPStatusCode: http.StatusMethodNotAllowed,
+			StatusText:     "Method not allowed",
+		}); err != nil {
+			o.renderFailed(r, "methodNotAllowedHandler", err)
+		}
+	}
+}
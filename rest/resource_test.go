@@ -0,0 +1,292 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+func TestResource(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		method       string
+		path         string
+		payload      string
+		withEntity   bool
+		readOnly     bool
+		wantedStatus int
+		wantedAllow  string
+	}{
+		{
+			name:         "list",
+			opts:         []Option{WithPath("/e")},
+			method:       "GET",
+			path:         "/e",
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "create",
+			opts:         []Option{WithPath("/e")},
+			method:       "POST",
+			path:         "/e",
+			payload:      `{"status_id": 1}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "get",
+			opts:         []Option{WithPath("/e")},
+			method:       "GET",
+			path:         "/e/",
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "replace",
+			opts:         []Option{WithPath("/e")},
+			method:       "PUT",
+			path:         "/e/",
+			payload:      `{"status_id": 2}`,
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "patch",
+			opts:         []Option{WithPath("/e")},
+			method:       "PATCH",
+			path:         "/e/",
+			payload:      `{"status_id": 2}`,
+			withEntity:   true,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "delete",
+			opts:         []Option{WithPath("/e")},
+			method:       "DELETE",
+			path:         "/e/",
+			withEntity:   true,
+			wantedStatus: http.StatusAccepted,
+		},
+		{
+			name: "get with custom id param",
+			opts: []Option{
+				WithPath("/e"), WithIDParam("entityID"),
+			},
+			method:       "GET",
+			path:         "/e/",
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name: "disabled patch",
+			opts: []Option{
+				WithPath("/e"), WithoutOperations(OpPatch),
+			},
+			method:       "PATCH",
+			path:         "/e/",
+			payload:      `{"status_id": 2}`,
+			withEntity:   true,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, PUT, DELETE, OPTIONS",
+		},
+		{
+			name: "read only",
+			opts: []Option{
+				WithPath("/e"), WithOperations(OpGet | OpList),
+			},
+			method:       "POST",
+			path:         "/e",
+			payload:      `{"status_id": 1}`,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, OPTIONS",
+		},
+		{
+			name:         "bulk create",
+			opts:         []Option{WithPath("/e")},
+			method:       "POST",
+			path:         "/e",
+			payload:      ` [{"status_id": 1}, {"status_id": 2}]`,
+			wantedStatus: http.StatusMultiStatus,
+		},
+		{
+			name:         "bulk patch",
+			opts:         []Option{WithPath("/e")},
+			method:       "PATCH",
+			path:         "/e",
+			payload:      `[]`,
+			wantedStatus: http.StatusMultiStatus,
+		},
+		{
+			name:         "bulk delete",
+			opts:         []Option{WithPath("/e")},
+			method:       "DELETE",
+			path:         "/e?ids=" + xid.New().String(),
+			wantedStatus: http.StatusMultiStatus,
+		},
+		{
+			name: "disabled bulk operations",
+			opts: []Option{
+				WithPath("/e"), WithoutOperations(OpBulk),
+			},
+			method:       "PATCH",
+			path:         "/e",
+			payload:      `[]`,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, POST, OPTIONS",
+		},
+		{
+			name: "bulk create only",
+			opts: []Option{
+				WithPath("/e"), WithoutOperations(OpCreate),
+			},
+			method:       "POST",
+			path:         "/e",
+			payload:      `{"status_id": 1}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "options",
+			opts:         []Option{WithPath("/e")},
+			method:       "OPTIONS",
+			path:         "/e/",
+			withEntity:   true,
+			wantedStatus: http.StatusNoContent,
+			wantedAllow:  "GET, HEAD, PUT, PATCH, DELETE, OPTIONS",
+		},
+		{
+			name:         "options of the collection",
+			opts:         []Option{WithPath("/e")},
+			method:       "OPTIONS",
+			path:         "/e",
+			wantedStatus: http.StatusNoContent,
+			wantedAllow:  "GET, HEAD, POST, PATCH, DELETE, OPTIONS",
+		},
+		{
+			name:         "head",
+			opts:         []Option{WithPath("/e")},
+			method:       "HEAD",
+			path:         "/e/",
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "head of the collection",
+			opts:         []Option{WithPath("/e")},
+			method:       "HEAD",
+			path:         "/e",
+			withEntity:   true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "read only manager, get",
+			opts:         []Option{WithPath("/e")},
+			method:       "GET",
+			path:         "/e/",
+			withEntity:   true,
+			readOnly:     true,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "read only manager, create",
+			opts:         []Option{WithPath("/e")},
+			method:       "POST",
+			path:         "/e",
+			payload:      `{"status_id": 1}`,
+			readOnly:     true,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, OPTIONS",
+		},
+		{
+			name:         "read only manager, bulk delete",
+			opts:         []Option{WithPath("/e")},
+			method:       "DELETE",
+			path:         "/e",
+			readOnly:     true,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, OPTIONS",
+		},
+		{
+			name:         "read only manager, delete",
+			opts:         []Option{WithPath("/e")},
+			method:       "DELETE",
+			path:         "/e/",
+			withEntity:   true,
+			readOnly:     true,
+			wantedStatus: http.StatusMethodNotAllowed,
+			wantedAllow:  "GET, HEAD, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, newHandler := range map[string]func(
+				interface{},
+			) http.Handler{
+				"http.Handler": func(m interface{}) http.Handler {
+					return NewResource(m, tt.opts...)
+				},
+				"chi.Router": func(m interface{}) http.Handler {
+					r := chi.NewRouter()
+					Mount(r, m, tt.opts...)
+					return r
+				},
+			} {
+				m := mock.NewMgr()
+				path := tt.path
+				if tt.withEntity {
+					ec, _ := m.Create(ctx, m.NewEmptyEntity(), nil)
+					if path != "/e" {
+						path += ec.(*mock.Entity).ID.String()
+					}
+				}
+
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest(
+					tt.method, path, bytes.NewBufferString(tt.payload),
+				)
+				if tt.method == "PATCH" {
+					req.Header.Set("Content-Type", MergePatchContentType)
+				}
+
+				var cmgr interface{} = m
+				if tt.readOnly {
+					cmgr = struct {
+						crud.Getter
+						crud.Lister
+						crud.ErrorMapper
+					}{m, m, m}
+				}
+				newHandler(cmgr).ServeHTTP(rr, req)
+
+				if status := rr.Code; status != tt.wantedStatus {
+					t.Errorf("%s returned wrong status code: got %v want %v",
+						name, status, tt.wantedStatus)
+				}
+				if allow := rr.Header().Get("Allow"); allow != tt.wantedAllow {
+					t.Errorf("%s returned wrong Allow header: got %q want %q",
+						name, allow, tt.wantedAllow)
+				}
+			}
+		})
+	}
+}
+
+func TestNewResourcePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewResource didn't panic without operations")
+		}
+	}()
+
+	NewResource(struct{ crud.ErrorMapper }{mock.NewMgr()})
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+// encodeJSON encodes v as the JSON body of a response
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// respond writes v as JSON with status
+func respond(w http.ResponseWriter, status int, v interface{}) error {
+	b, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	return write(w, status, b)
+}
+
+func write(w http.ResponseWriter, status int, b []byte) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(status)
+	_, err := w.Write(b)
+	return err
+}
+
+// respondConditional writes v as JSON, along with its ETag and
+// Last-Modified headers, or 304 Not Modified if the client has it already
+// etag is the ETag of v, a hash of its body when empty
+func respondConditional(
+	w http.ResponseWriter,
+	r *http.Request,
+	v interface{},
+	etag string,
+	lastModified time.Time,
+) error {
+	b, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+
+	if etag == "" {
+		etag = bodyETag(b)
+	}
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified",
+			lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	return write(w, http.StatusOK, b)
+}
+
+// bodyETag returns the ETag of a response body, a hash of it
+func bodyETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return formatETag(hex.EncodeToString(sum[:16]))
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Values("If-None-Match"); len(inm) > 0 {
+		return etagsMatch(inm, etag, true)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// lastModified returns when e last changed, or the latest change of its
+// items if e is a list, zero if cmgr is not a crud.LastModifier
+func lastModified(cmgr interface{}, e interface{}, list bool) time.Time {
+	lmr, ok := cmgr.(crud.LastModifier)
+	if !ok || e == nil {
+		return time.Time{}
+	}
+	if !list {
+		return lmr.LastModified(e)
+	}
+
+	var latest time.Time
+	items := reflect.ValueOf(e)
+	if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		return latest
+	}
+	for i := 0; i < items.Len(); i++ {
+		if t := lmr.LastModified(items.Index(i).Interface()); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
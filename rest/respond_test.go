@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/induzo/crud/mock"
+)
+
+var modifiedAt = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// lastModMgr is a versionMgr whose entities all changed at modifiedAt
+type lastModMgr struct {
+	*versionMgr
+}
+
+func (m lastModMgr) LastModified(e interface{}) time.Time {
+	return modifiedAt
+}
+
+func TestConditionalGET(t *testing.T) {
+	m := lastModMgr{&versionMgr{Mgr: mock.NewMgr()}}
+	ec, _ := m.Create(context.Background(), &mock.Entity{StatusID: 1}, nil)
+	id := ec.(*mock.Entity).ID
+
+	get := func(
+		h func(http.ResponseWriter, *http.Request),
+		url string,
+		header http.Header,
+	) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", url, nil)
+		req = req.WithContext(GetTestContextWithID(req.Context(), id))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		h(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name         string
+		handler      func(http.ResponseWriter, *http.Request)
+		url          string
+		header       http.Header
+		wantedStatus int
+	}{
+		{
+			name:         "entity, no condition",
+			handler:      GETHandler(m),
+			url:          "/entity",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "entity, matching ETag",
+			handler:      GETHandler(m),
+			url:          "/entity",
+			header:       http.Header{"If-None-Match": {`W/"1"`}},
+			wantedStatus: http.StatusNotModified,
+		},
+		{
+			name:         "entity, other ETag",
+			handler:      GETHandler(m),
+			url:          "/entity",
+			header:       http.Header{"If-None-Match": {`"0"`}},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:    "entity, ETag wins over If-Modified-Since",
+			handler: GETHandler(m),
+			url:     "/entity",
+			header: http.Header{
+				"If-None-Match":     {`"0"`},
+				"If-Modified-Since": {modifiedAt.Format(http.TimeFormat)},
+			},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:    "entity, not modified since",
+			handler: GETHandler(m),
+			url:     "/entity",
+			header: http.Header{
+				"If-Modified-Since": {modifiedAt.Format(http.TimeFormat)},
+			},
+			wantedStatus: http.StatusNotModified,
+		},
+		{
+			name:    "entity, modified since",
+			handler: GETHandler(m),
+			url:     "/entity",
+			header: http.Header{
+				"If-Modified-Since": {
+					modifiedAt.Add(-time.Hour).Format(http.TimeFormat),
+				},
+			},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:    "list, not modified since",
+			handler: GETListHandler(m),
+			url:     "/entity",
+			header: http.Header{
+				"If-Modified-Since": {modifiedAt.Format(http.TimeFormat)},
+			},
+			wantedStatus: http.StatusNotModified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.handler, tt.url, tt.header)
+			if rr.Code != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if rr.Code == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("304 with a body: %s", rr.Body)
+			}
+			if got := rr.Header().Get("Last-Modified"); got !=
+				modifiedAt.Format(http.TimeFormat) {
+				t.Errorf("handler returned Last-Modified %q", got)
+			}
+		})
+	}
+
+	// without versions, the ETag is a hash of the body
+	for name, h := range map[string]func(http.ResponseWriter, *http.Request){
+		"GETHandler":              GETHandler(m.Mgr),
+		"GETHandler with fields":  GETHandler(m, WithPath("/")),
+		"GETListHandler":          GETListHandler(m.Mgr),
+		"GETListHandler envelope": GETListHandler(m.Mgr, WithListEnvelope()),
+	} {
+		url := "/entity"
+		if name == "GETHandler with fields" {
+			url += "?fields=status_id"
+		}
+		rr := get(h, url, nil)
+		etag := rr.Header().Get("ETag")
+		if rr.Code != http.StatusOK || len(etag) < 3 {
+			t.Errorf("%s: got status %d and ETag %q", name, rr.Code, etag)
+			continue
+		}
+		rr = get(h, url, http.Header{"If-None-Match": {etag}})
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("%s: got status %d with If-None-Match: %s",
+				name, rr.Code, etag)
+		}
+	}
+}
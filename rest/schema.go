@@ -0,0 +1,31 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+// SchemaPath is the path of the JSON Schema of the entities,
+// under the path of a Resource
+const SchemaPath = "/_schema"
+
+// SchemaHandler returns the JSON Schema of the entities of cmgr,
+// draft 2020-12, see crud.EntitySchema
+// The schema is the one WithSchemaValidation validates the bodies with
+func SchemaHandler(
+	cmgr crud.EntityFactory,
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	o := newOptions(opts)
+	schema := crud.EntitySchema(cmgr.NewEmptyEntity())
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := respondConditional(
+			w, r, schema, "", time.Time{},
+		); err != nil {
+			o.renderFailed(r, "SchemaHandler", err)
+		}
+	}
+}
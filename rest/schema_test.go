@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+func TestSchemaHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	SchemaHandler(&validMgr{})(rr, httptest.NewRequest("GET", "/_schema", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v",
+			rr.Code, http.StatusOK)
+	}
+	var s crud.Schema
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatalf("handler returned an invalid schema: %v", err)
+	}
+	if s.Schema != crud.SchemaDialect ||
+		!reflect.DeepEqual(s.Required, []string{"name"}) ||
+		s.Properties["id"] == nil || !s.Properties["id"].ReadOnly {
+		t.Errorf("handler returned %s", rr.Body.String())
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Errorf("handler returned no ETag")
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		opts         []Option
+		wantedStatus int
+		wantedErrors []crud.FieldError
+	}{
+		{
+			name:         "valid POST",
+			method:       "POST",
+			body:         `{"name": "pol", "status": 1}`,
+			opts:         []Option{WithSchemaValidation()},
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "invalid POST",
+			method:       "POST",
+			body:         `{"name": 1, "status": "x"}`,
+			opts:         []Option{WithSchemaValidation()},
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/name", Detail: "must be a string"},
+				{Pointer: "/status", Detail: "must be an integer"},
+			},
+		},
+		{
+			name:         "invalid POST without schema validation",
+			method:       "POST",
+			body:         `{"name": 1, "status": "x"}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid PUT",
+			method:       "PUT",
+			body:         `{"status": 1.5}`,
+			opts:         []Option{WithSchemaValidation()},
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/name", Detail: "is required"},
+				{Pointer: "/status", Detail: "must be an integer"},
+			},
+		},
+		{
+			name:   "PUT with the read only id",
+			method: "PUT",
+			body: `{
+				"id": "9m4e2mr0ui3e8a215n4g", "name": "pol", "status": 1
+			}`,
+			opts:         []Option{WithSchemaValidation()},
+			wantedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &validMgr{}
+			h := POSTHandler(m, tt.opts...)
+			if tt.method == "PUT" {
+				h = PUTHandler(m, tt.opts...)
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/entity",
+				bytes.NewBufferString(tt.body))
+			req = req.WithContext(GetTestContextWithID(req.Context(), xid.New()))
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if m.called != (rr.Code < 300) {
+				t.Errorf("manager called: %v", m.called)
+			}
+			if tt.wantedErrors == nil {
+				return
+			}
+			var p struct {
+				Errors []crud.FieldError `json:"errors"`
+			}
+			_ = json.NewDecoder(rr.Body).Decode(&p)
+			if !reflect.DeepEqual(p.Errors, tt.wantedErrors) {
+				t.Errorf("handler returned errors %v, want %v",
+					p.Errors, tt.wantedErrors)
+			}
+		})
+	}
+}
+
+func TestBulkSchemaValidation(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/e", bytes.NewBufferString(
+		`[{"name": "pol", "status": 1}, {"name": 1, "status": 1}]`,
+	))
+
+	BulkPOSTHandler(&validMgr{}, WithSchemaValidation())(rr, req)
+
+	var res BulkResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	if rr.Code != http.StatusMultiStatus || len(res.Results) != 2 ||
+		res.Results[0].Status != http.StatusCreated ||
+		res.Results[1].Status != http.StatusUnprocessableEntity {
+		t.Errorf("handler returned %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestResourceSchema(t *testing.T) {
+	res := NewResource(mock.NewMgr(), WithPath("/e"))
+
+	for method, wantedStatus := range map[string]int{
+		"GET":     http.StatusOK,
+		"HEAD":    http.StatusOK,
+		"OPTIONS": http.StatusNoContent,
+		"POST":    http.StatusMethodNotAllowed,
+	} {
+		rr := httptest.NewRecorder()
+		res.ServeHTTP(rr, httptest.NewRequest(method, "/e/_schema", nil))
+
+		if rr.Code != wantedStatus {
+			t.Errorf("%s returned wrong status code: got %v want %v",
+				method, rr.Code, wantedStatus)
+		}
+		if method == "GET" && rr.Body.Len() == 0 ||
+			method != "GET" && rr.Body.Len() > 0 && method != "POST" {
+			t.Errorf("%s returned the body %q", method, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	NewResource(mock.NewMgr(), WithPath("/e"), WithoutOperations(OpSchema)).
+		ServeHTTP(rr, httptest.NewRequest("GET", "/e/_schema", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("disabled schema returned %v, want %v, as an invalid id",
+			rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/induzo/crud"
+)
+
+// POSTHandlerOf is the type safe version of POSTHandler
+func POSTHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return POSTHandler(crud.Adapt(m), opts...)
+}
+
+// GETListHandlerOf is the type safe version of GETListHandler
+func GETListHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return GETListHandler(crud.Adapt(m), opts...)
+}
+
+// GETHandlerOf is the type safe version of GETHandler
+func GETHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return GETHandler(crud.Adapt(m), opts...)
+}
+
+// DELETEHandlerOf is the type safe version of DELETEHandler
+func DELETEHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return DELETEHandler(crud.Adapt(m), opts...)
+}
+
+// PUTHandlerOf is the type safe version of PUTHandler
+func PUTHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return PUTHandler(crud.Adapt(m), opts...)
+}
+
+// PATCHHandlerOf is the type safe version of PATCHHandler
+func PATCHHandlerOf[K crud.ID, T any](
+	m crud.Manager[K, T],
+	opts ...Option,
+) func(w http.ResponseWriter, r *http.Request) {
+	return PATCHHandler(crud.Adapt(m), opts...)
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/crud/mock"
+	"github.com/rs/xid"
+)
+
+func TestTypedHandlers(t *testing.T) {
+	ctx := context.Background()
+	m := mock.NewTypedMgr()
+	ec, _ := m.Create(ctx, m.NewEmptyEntity(), bytes.NewReader([]byte{}))
+
+	tests := []struct {
+		name         string
+		handler      func(w http.ResponseWriter, r *http.Request)
+		method       string
+		id           xid.ID
+		payload      string
+		wantedStatus int
+	}{
+		{
+			name:         "working POST",
+			handler:      POSTHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "POST",
+			payload:      `{"status_id": 1}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "working GETList",
+			handler:      GETListHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "GET",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "working GET",
+			handler:      GETHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "GET",
+			id:           ec.ID,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "working PUT",
+			handler:      PUTHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "PUT",
+			id:           ec.ID,
+			payload:      `{"status_id": 3}`,
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "working PATCH",
+			handler:      PATCHHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "PATCH",
+			id:           ec.ID,
+			payload:      `{"status_id": 2}`,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "bad patch, non working PATCH",
+			handler:      PATCHHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "PATCH",
+			id:           ec.ID,
+			payload:      `{"status_id": "2"}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "working DELETE",
+			handler:      DELETEHandlerOf[xid.ID, *mock.Entity](m),
+			method:       "DELETE",
+			id:           ec.ID,
+			wantedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(
+				tt.method, `http://dummy/entity`,
+				bytes.NewBufferString(tt.payload),
+			)
+			if tt.method == "PATCH" {
+				req.Header.Set("Content-Type", MergePatchContentType)
+			}
+			if !tt.id.IsNil() {
+				req = req.WithContext(
+					GetTestContextWithID(req.Context(), tt.id),
+				)
+			}
+
+			tt.handler(rr, req)
+			resp := rr.Result()
+			defer resp.Body.Close()
+
+			if status := resp.StatusCode; status != tt.wantedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.wantedStatus)
+			}
+		})
+	}
+}
+
+func TestAdaptWrongEntity(t *testing.T) {
+	m := crud.Adapt[xid.ID, *mock.Entity](mock.NewTypedMgr())
+
+	_, err := m.Create(context.Background(), mock.Entity{}, nil)
+	if !errors.Is(err, crud.ErrEntityType) {
+		t.Errorf("Adapt Create: got %v, want %v", err, crud.ErrEntityType)
+	}
+
+	e := m.NewEmptyEntity()
+	if err := json.Unmarshal([]byte(`{"status_id": 4}`), e); err != nil {
+		t.Errorf("Adapt NewEmptyEntity: %v", err)
+	}
+}
+
+func TestAdaptInvalidID(t *testing.T) {
+	m := crud.Adapt[xid.ID, *mock.Entity](mock.NewTypedMgr())
+
+	_, err := m.Get(context.Background(), crud.Int64ID(1))
+	if !errors.Is(err, crud.ErrInvalidID) {
+		t.Fatalf("Adapt Get: got %v, want %v", err, crud.ErrInvalidID)
+	}
+	if status := mapError(m, err).HTTPStatusCode; status !=
+		http.StatusBadRequest {
+		t.Errorf("mapError returned %d, want %d",
+			status, http.StatusBadRequest)
+	}
+}
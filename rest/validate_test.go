@@ -0,0 +1,183 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/rs/xid"
+)
+
+type validEntity struct {
+	ID     xid.ID `json:"id"`
+	Name   string `json:"name" validate:"required,max=5"`
+	Status int    `json:"status" validate:"min=1"`
+}
+
+// validMgr is a manager of entities with validate tags,
+// recording if it got an entity
+type validMgr struct {
+	called bool
+}
+
+func (m *validMgr) NewEmptyEntity() interface{} {
+	return &validEntity{}
+}
+
+func (m *validMgr) Create(
+	ctx context.Context,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	m.called = true
+	return e, nil
+}
+
+func (m *validMgr) Update(
+	ctx context.Context,
+	id crud.ID,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	m.called = true
+	return e, nil
+}
+
+func (m *validMgr) PartialUpdate(
+	ctx context.Context,
+	id crud.ID,
+	pud crud.PartialUpdateData,
+	pl io.Reader,
+) error {
+	m.called = true
+	return nil
+}
+
+// validGetMgr is a validMgr also able to get its entity
+type validGetMgr struct {
+	validMgr
+}
+
+func (m *validGetMgr) Get(
+	ctx context.Context,
+	id crud.ID,
+) (interface{}, error) {
+	return &validEntity{ID: id.(xid.ID), Name: "pol", Status: 1}, nil
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		withGet      bool
+		body         string
+		wantedStatus int
+		wantedErrors []crud.FieldError
+	}{
+		{
+			name:         "valid POST",
+			method:       "POST",
+			body:         `{"name": "pol", "status": 1}`,
+			wantedStatus: http.StatusCreated,
+		},
+		{
+			name:         "invalid POST",
+			method:       "POST",
+			body:         `{"name": "", "status": 0}`,
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/name", Detail: "is required"},
+				{Pointer: "/status", Detail: "must be at least 1"},
+			},
+		},
+		{
+			name:         "invalid PUT",
+			method:       "PUT",
+			body:         `{"name": "polpol", "status": 1}`,
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/name", Detail: "must have a length of at most 5"},
+			},
+		},
+		{
+			name:         "PATCH of the valid fields only",
+			method:       "PATCH",
+			body:         `{"name": "pol"}`,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "invalid PATCH",
+			method:       "PATCH",
+			body:         `{"name": "pol", "status": 0}`,
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/status", Detail: "must be at least 1"},
+			},
+		},
+		{
+			name:         "PATCH of the current entity",
+			method:       "PATCH",
+			withGet:      true,
+			body:         `{"status": 2}`,
+			wantedStatus: http.StatusNoContent,
+		},
+		{
+			name:         "PATCH removing a required field",
+			method:       "PATCH",
+			withGet:      true,
+			body:         `{"name": null}`,
+			wantedStatus: http.StatusUnprocessableEntity,
+			wantedErrors: []crud.FieldError{
+				{Pointer: "/name", Detail: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &validGetMgr{}
+			var cmgr interface{} = &m.validMgr
+			if tt.withGet {
+				cmgr = m
+			}
+			h := map[string]func(http.ResponseWriter, *http.Request){
+				"POST":  POSTHandler(&m.validMgr),
+				"PUT":   PUTHandler(&m.validMgr),
+				"PATCH": PATCHHandler(cmgr),
+			}[tt.method]
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/entity",
+				bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", MergePatchContentType)
+			req = req.WithContext(GetTestContextWithID(req.Context(), xid.New()))
+
+			h(rr, req)
+
+			if rr.Code != tt.wantedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v",
+					rr.Code, tt.wantedStatus)
+			}
+			if m.called != (rr.Code < 300) {
+				t.Errorf("manager called: %v", m.called)
+			}
+			if tt.wantedErrors == nil {
+				return
+			}
+			var p struct {
+				Errors []crud.FieldError `json:"errors"`
+			}
+			_ = json.NewDecoder(rr.Body).Decode(&p)
+			if !reflect.DeepEqual(p.Errors, tt.wantedErrors) {
+				t.Errorf("handler returned errors %v, want %v",
+					p.Errors, tt.wantedErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package crud
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema dialect of the schemas
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, draft 2020-12, restricted to the keywords
+// reflected from Go types
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        SchemaType         `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	ReadOnly    bool               `json:"readOnly,omitempty"`
+	WriteOnly   bool               `json:"writeOnly,omitempty"`
+	AnyOf       []*Schema          `json:"anyOf,omitempty"`
+	// ContentEncoding is base64 for []byte
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+}
+
+// SchemaType is the type keyword of a Schema, a single type
+// or a list of them, rendered as a string when alone
+type SchemaType []string
+
+// Has returns true if name is one of the types
+func (t SchemaType) Has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON renders a single type as a string
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON accepts a type or a list of them
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = SchemaType{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// SchemaProvider is a type describing its own JSON form,
+// JSON Schemas use it instead of reflecting the type
+type SchemaProvider interface {
+	JSONSchema() *Schema
+}
+
+// SchemaOf returns the standalone JSON Schema of the JSON form of v,
+// the named structs it is made of being in $defs
+// A pointer is described as the value it points to, entities being
+// usually pointers to structs
+// See SchemaReflector for how Go types are reflected
+func SchemaOf(v interface{}) *Schema {
+	sr := &SchemaReflector{RefPrefix: "#/$defs/"}
+	return sr.standalone(sr.Reflect(elemType(v)))
+}
+
+// EntitySchema returns the standalone JSON Schema of the entity e,
+// as SchemaOf does, its id field being read only, see ReflectEntity
+func EntitySchema(e interface{}) *Schema {
+	sr := &SchemaReflector{RefPrefix: "#/$defs/"}
+	return sr.standalone(sr.ReflectEntity(elemType(e)))
+}
+
+// elemType returns the type of v, or of what it points to
+func elemType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// standalone returns s, reflected by sr, with the dialect and definitions
+// of a standalone schema
+func (sr *SchemaReflector) standalone(s *Schema) *Schema {
+
+	// inline the root definition, unless it is recursive
+	if name := strings.TrimPrefix(s.Ref, sr.RefPrefix); s.Ref != "" {
+		root := *sr.Defs[name]
+		if sr.refs[name] == 1 {
+			delete(sr.Defs, name)
+		}
+		s = &root
+	}
+	s.Schema = SchemaDialect
+	if len(sr.Defs) > 0 {
+		s.Defs = sr.Defs
+	}
+	return s
+}
+
+// SchemaReflector builds the JSON Schemas of Go types, the named structs
+// being definitions referenced with $ref, so that several schemas can
+// share them, in the components of an OpenAPI document for example
+//
+// Types follow encoding/json: the fields are the JSON fields of structs,
+// encoding.TextMarshaler types are strings, json.Marshaler ones are
+// anything, time.Time is a date-time string and pointers can be null
+// A SchemaProvider type gives its own schema
+//
+// The validate tags of the fields, see Validate, add their keywords:
+// required, minimum and maximum, minLength and maxLength, minItems and
+// maxItems, enum for oneof and the email format
+// The description tag of a field is its description, and its schema tag
+// can be readonly, for the fields clients cannot set, or writeonly, for
+// the ones never rendered
+type SchemaReflector struct {
+	// RefPrefix is the prefix of the $ref to the definitions,
+	// #/components/schemas/ in an OpenAPI document
+	RefPrefix string
+	// Defs are the definitions of the named types, by name
+	Defs map[string]*Schema
+
+	names map[reflect.Type]string
+	refs  map[string]int
+}
+
+var (
+	schemaProviderType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf(
+		(*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	invalidDefChars   = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// ReflectEntity returns the schema of the entity type t, as Reflect does,
+// its id JSON field being read only unless it has a schema tag
+func (sr *SchemaReflector) ReflectEntity(t reflect.Type) *Schema {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := sr.Reflect(t)
+
+	def := s
+	if s.Ref != "" {
+		def = sr.Defs[strings.TrimPrefix(s.Ref, sr.RefPrefix)]
+	}
+	f, ok := JSONField(t, "id")
+	if p := def.Properties["id"]; ok && p != nil && f.Tag.Get("schema") == "" {
+		p.ReadOnly = true
+	}
+	return s
+}
+
+// Reflect returns the schema of the type t
+func (sr *SchemaReflector) Reflect(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Ptr {
+		return nullable(sr.Reflect(t.Elem()))
+	}
+
+	if s, ok := provideSchema(t); ok {
+		if t.Name() == "" {
+			return s
+		}
+		return sr.define(t, func() *Schema { return s })
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaType{"string"}, Format: "date-time"}
+	case t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: SchemaType{"string"}}
+	case t.Implements(jsonMarshalerType) ||
+		reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: SchemaType{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return &Schema{Type: SchemaType{"integer"}, Format: intFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return &Schema{
+			Type:    SchemaType{"integer"},
+			Format:  intFormat(t),
+			Minimum: float(0),
+		}
+	case reflect.Float32:
+		return &Schema{Type: SchemaType{"number"}, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: SchemaType{"number"}, Format: "double"}
+	case reflect.String:
+		return &Schema{Type: SchemaType{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice &&
+			!t.Elem().Implements(textMarshalerType) {
+			return &Schema{
+				Type:            SchemaType{"string"},
+				ContentEncoding: "base64",
+			}
+		}
+		s := &Schema{Type: SchemaType{"array"}, Items: sr.Reflect(t.Elem())}
+		if t.Kind() == reflect.Array {
+			s.MinItems, s.MaxItems = integer(t.Len()), integer(t.Len())
+		}
+		return s
+	case reflect.Map:
+		return &Schema{
+			Type:                 SchemaType{"object"},
+			AdditionalProperties: sr.Reflect(t.Elem()),
+		}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.structSchema(t)
+		}
+		return sr.define(t, func() *Schema { return sr.structSchema(t) })
+	default:
+		return &Schema{}
+	}
+}
+
+// provideSchema returns the schema of a SchemaProvider type
+func provideSchema(t reflect.Type) (*Schema, bool) {
+	switch {
+	case t.Kind() == reflect.Interface:
+	case t.Implements(schemaProviderType):
+		return reflect.New(t).Elem().Interface().(SchemaProvider).
+			JSONSchema(), true
+	case reflect.PtrTo(t).Implements(schemaProviderType):
+		return reflect.New(t).Interface().(SchemaProvider).JSONSchema(), true
+	}
+	return nil, false
+}
+
+// define returns a reference to the definition of the named type t,
+// built with build the first time
+func (sr *SchemaReflector) define(
+	t reflect.Type,
+	build func() *Schema,
+) *Schema {
+	if sr.names == nil {
+		sr.names = make(map[reflect.Type]string)
+		sr.refs = make(map[string]int)
+	}
+	if sr.Defs == nil {
+		sr.Defs = make(map[string]*Schema)
+	}
+
+	name, ok := sr.names[t]
+	if !ok {
+		name = sr.defName(t)
+		sr.names[t] = name
+		// set first for recursive types
+		sr.Defs[name] = &Schema{}
+		*sr.Defs[name] = *build()
+	}
+	sr.refs[name]++
+	return &Schema{Ref: sr.RefPrefix + name}
+}
+
+// defName returns an unused definition name for t,
+// prefixed by its package if its name is already taken
+func (sr *SchemaReflector) defName(t reflect.Type) string {
+	name := invalidDefChars.ReplaceAllString(t.Name(), "_")
+	if _, taken := sr.Defs[name]; !taken {
+		return name
+	}
+	name = path.Base(t.PkgPath()) + "." + name
+	n := name
+	for i := 2; ; i++ {
+		if _, taken := sr.Defs[n]; !taken {
+			return n
+		}
+		n = name + strconv.Itoa(i)
+	}
+}
+
+func (sr *SchemaReflector) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       SchemaType{"object"},
+		Properties: make(map[string]*Schema),
+	}
+	for _, f := range JSONFields(t) {
+		var fs *Schema
+		if hasJSONOption(f.Tag.Get("json"), "string") && isScalar(f.Type) {
+			fs = &Schema{Type: SchemaType{"string"}}
+		} else {
+			fs = sr.Reflect(f.Type)
+		}
+		d, access := f.Tag.Get("description"), f.Tag.Get("schema")
+		if fs.Ref != "" && (d != "" || access != "") {
+			// annotate the reference, not the shared definition
+			fs = &Schema{Ref: fs.Ref}
+		}
+		if d != "" {
+			fs.Description = d
+		}
+		switch access {
+		case "readonly":
+			fs.ReadOnly = true
+		case "writeonly":
+			fs.WriteOnly = true
+		}
+		if tag := f.Tag.Get("validate"); tag != "" {
+			if applyRules(sr, fs, f.Type, tag) {
+				s.Required = append(s.Required, f.Name)
+			}
+		}
+		s.Properties[f.Name] = fs
+	}
+	return s
+}
+
+// applyRules adds the keywords of the validate rules of tag to s,
+// the schema of a field of type t, it returns true if it is required
+// Unknown rules are left to Validate to report
+func applyRules(
+	sr *SchemaReflector,
+	s *Schema,
+	t reflect.Type,
+	tag string,
+) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// the keywords of nullable or referenced types go on their variant
+	target := s
+	if len(s.AnyOf) > 0 {
+		target = s.AnyOf[0]
+	}
+	if target.Ref != "" {
+		return strings.Contains(","+tag+",", ",required,")
+	}
+
+	var required bool
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			for _, o := range strings.Fields(arg) {
+				target.Enum = append(target.Enum, enumValue(t, o))
+			}
+		case "email":
+			target.Format = "email"
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(target, t.Kind(), name, n)
+		}
+	}
+	return required
+}
+
+// applyBound adds the keywords of the min, max or len rule n
+// to the schema s of a kind k
+func applyBound(s *Schema, k reflect.Kind, name string, n float64) {
+	var min, max **int
+	switch k {
+	case reflect.String:
+		min, max = &s.MinLength, &s.MaxLength
+	case reflect.Slice, reflect.Array:
+		min, max = &s.MinItems, &s.MaxItems
+	case reflect.Map:
+		min, max = &s.MinProperties, &s.MaxProperties
+	default:
+		switch name {
+		case "min":
+			s.Minimum = float(n)
+		case "max":
+			s.Maximum = float(n)
+		}
+		return
+	}
+	if name != "max" {
+		*min = integer(int(n))
+	}
+	if name != "min" {
+		*max = integer(int(n))
+	}
+}
+
+// enumValue returns the oneof value v as a JSON value of type t
+func enumValue(t reflect.Type, v string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// nullable returns s also accepting null
+func nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: SchemaType{"null"}}}}
+	case len(s.Type) == 0 || s.Type.Has("null"):
+		return s
+	}
+	s.Type = append(s.Type, "null")
+	return s
+}
+
+func intFormat(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int32, reflect.Uint32:
+		return "int32"
+	case reflect.Int64, reflect.Uint64:
+		return "int64"
+	}
+	return ""
+}
+
+// isScalar returns true for the types encoding/json quotes
+// with the string option
+func isScalar(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+func hasJSONOption(tag, opt string) bool {
+	_, opts, _ := strings.Cut(tag, ",")
+	return strings.Contains(","+opts+",", ","+opt+",")
+}
+
+func integer(n int) *int {
+	return &n
+}
+
+func float(n float64) *float64 {
+	return &n
+}
@@ -0,0 +1,206 @@
+package crud
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type schemaNode struct {
+	Value    int           `json:"value"`
+	Children []*schemaNode `json:"children,omitempty"`
+}
+
+type schemaEmbedded struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type schemaEntity struct {
+	schemaEmbedded
+	ID      xid.ID            `json:"id"`
+	Name    string            `json:"name" validate:"required,min=2,max=5" description:"the name"`
+	Age     uint8             `json:"age" validate:"max=120"`
+	Score   int64             `json:"score,string"`
+	Email   *string           `json:"email,omitempty" validate:"omitempty,email"`
+	Level   int               `json:"level" validate:"oneof=1 2 3"`
+	Tags    []string          `json:"tags" validate:"len=2"`
+	Address *validAddress     `json:"address" validate:"required"`
+	Labels  map[string]string `json:"labels"`
+	Raw     json.RawMessage   `json:"raw"`
+	Data    []byte            `json:"data"`
+	Hidden  string            `json:"-"`
+}
+
+type schemaCustom struct{}
+
+func (schemaCustom) JSONSchema() *Schema {
+	return &Schema{Type: SchemaType{"string"}, Format: "custom"}
+}
+
+func TestSchemaOf(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "scalar",
+			v:    float32(0),
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "number", "format": "float"
+			}`,
+		},
+		{
+			name: "entity",
+			v:    &schemaEntity{},
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"properties": {
+					"created_at": {"type": "string", "format": "date-time"},
+					"id": {"type": "string"},
+					"name": {
+						"type": "string", "description": "the name",
+						"minLength": 2, "maxLength": 5
+					},
+					"age": {"type": "integer", "minimum": 0, "maximum": 120},
+					"score": {"type": "string"},
+					"email": {"type": ["string", "null"], "format": "email"},
+					"level": {"type": "integer", "enum": [1, 2, 3]},
+					"tags": {
+						"type": "array", "items": {"type": "string"},
+						"minItems": 2, "maxItems": 2
+					},
+					"address": {"anyOf": [
+						{"$ref": "#/$defs/validAddress"}, {"type": "null"}
+					]},
+					"labels": {
+						"type": "object",
+						"additionalProperties": {"type": "string"}
+					},
+					"raw": {},
+					"data": {"type": "string", "contentEncoding": "base64"}
+				},
+				"required": ["name", "address"],
+				"$defs": {
+					"validAddress": {
+						"type": "object",
+						"properties": {
+							"city": {"type": "string"},
+							"zip": {
+								"type": "string", "minLength": 4, "maxLength": 4
+							}
+						},
+						"required": ["city"]
+					}
+				}
+			}`,
+		},
+		{
+			name: "recursive",
+			v:    schemaNode{},
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"properties": {
+					"value": {"type": "integer"},
+					"children": {"type": "array", "items": {"anyOf": [
+						{"$ref": "#/$defs/schemaNode"}, {"type": "null"}
+					]}}
+				},
+				"$defs": {
+					"schemaNode": {
+						"type": "object",
+						"properties": {
+							"value": {"type": "integer"},
+							"children": {"type": "array", "items": {"anyOf": [
+								{"$ref": "#/$defs/schemaNode"}, {"type": "null"}
+							]}}
+						}
+					}
+				}
+			}`,
+		},
+		{
+			name: "schema provider",
+			v:    []schemaCustom{},
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "array",
+				"items": {"$ref": "#/$defs/schemaCustom"},
+				"$defs": {
+					"schemaCustom": {"type": "string", "format": "custom"}
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(SchemaOf(tt.v))
+			if err != nil {
+				t.Fatalf("SchemaOf() can't be marshaled: %v", err)
+			}
+
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("SchemaOf() = %s", b)
+			}
+		})
+	}
+}
+
+func TestSchemaReflectorNames(t *testing.T) {
+	type validAddress struct {
+		Street string `json:"street"`
+	}
+	sr := &SchemaReflector{RefPrefix: "#/components/schemas/"}
+
+	a := sr.Reflect(reflect.TypeOf(validAddress{}))
+	b := sr.Reflect(reflect.TypeOf(validEntity{}))
+	c := sr.Reflect(reflect.TypeOf(validAddress{}))
+
+	if a.Ref != "#/components/schemas/validAddress" || a.Ref != c.Ref {
+		t.Errorf("Reflect() refs %q and %q", a.Ref, c.Ref)
+	}
+	if b.Ref != "#/components/schemas/validEntity" {
+		t.Errorf("Reflect() ref %q", b.Ref)
+	}
+	if _, ok := sr.Defs["crud.validAddress"]; !ok {
+		t.Errorf("Reflect() didn't prefix the colliding name: %v", sr.Defs)
+	}
+}
+
+type schemaAccount struct {
+	ID       int64  `json:"id"`
+	Login    string `json:"login" schema:"readonly"`
+	Password string `json:"password" schema:"writeonly"`
+}
+
+func TestEntitySchema(t *testing.T) {
+	s := EntitySchema(&schemaAccount{})
+
+	for name, want := range map[string][2]bool{
+		"id":       {true, false},
+		"login":    {true, false},
+		"password": {false, true},
+	} {
+		p := s.Properties[name]
+		if p == nil || p.ReadOnly != want[0] || p.WriteOnly != want[1] {
+			t.Errorf("EntitySchema() has %s %+v", name, p)
+		}
+	}
+	if s.Schema != SchemaDialect {
+		t.Errorf("EntitySchema() has the dialect %q", s.Schema)
+	}
+}
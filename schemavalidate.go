@@ -0,0 +1,304 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidateJSON validates the JSON document b against the schema,
+// see Validate
+func (s *Schema) ValidateJSON(b []byte) error {
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return err
+	}
+	return s.Validate(doc)
+}
+
+// Validate validates the JSON value v, as decoded by encoding/json,
+// against the schema, s being the root its references are resolved in
+// It returns a *ValidationError listing the invalid values
+// Annotations are ignored, readOnly and writeOnly among them,
+// as are the references to schemas out of the $defs of s
+func (s *Schema) Validate(v interface{}) error {
+	sv := &schemaValidator{root: s}
+	sv.validate(s, v, Pointer{})
+	if len(sv.errs) > 0 {
+		return &ValidationError{Errors: sv.errs}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root *Schema
+	errs []FieldError
+}
+
+func (sv *schemaValidator) fail(path Pointer, detail string) {
+	sv.errs = append(sv.errs, FieldError{
+		Pointer: path.String(),
+		Detail:  detail,
+	})
+}
+
+// resolve returns the schema of the reference ref, nil if unknown
+func (sv *schemaValidator) resolve(ref string) *Schema {
+	if ref == "#" {
+		return sv.root
+	}
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	if name == ref {
+		return nil
+	}
+	return sv.root.Defs[pointerUnescaper.Replace(name)]
+}
+
+func (sv *schemaValidator) validate(s *Schema, v interface{}, path Pointer) {
+	if s.Ref != "" {
+		if def := sv.resolve(s.Ref); def != nil {
+			n := len(sv.errs)
+			if sv.validate(def, v, path); len(sv.errs) > n {
+				return
+			}
+		}
+	}
+	if len(s.AnyOf) > 0 && !sv.anyOf(s.AnyOf, v, path) {
+		return
+	}
+	if len(s.Type) > 0 && !hasSchemaType(s.Type, v) {
+		sv.fail(path, "must be "+typeNames(s.Type))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		sv.fail(path, "must be one of "+strings.Join(values, ", "))
+		return
+	}
+
+	switch x := v.(type) {
+	case string:
+		sv.validateString(s, x, path)
+	case json.Number, float64:
+		sv.validateNumber(s, toFloat(x), path)
+	case []interface{}:
+		sv.validateArray(s, x, path)
+	case map[string]interface{}:
+		sv.validateObject(s, x, path)
+	}
+}
+
+// anyOf returns true if v is valid against one of the schemas,
+// reporting the errors of the first one otherwise
+func (sv *schemaValidator) anyOf(
+	schemas []*Schema,
+	v interface{},
+	path Pointer,
+) bool {
+	var first []FieldError
+	for i, s := range schemas {
+		sub := &schemaValidator{root: sv.root}
+		sub.validate(s, v, path)
+		if len(sub.errs) == 0 {
+			return true
+		}
+		if i == 0 {
+			first = sub.errs
+		}
+	}
+	sv.errs = append(sv.errs, first...)
+	return false
+}
+
+func (sv *schemaValidator) validateString(s *Schema, x string, path Pointer) {
+	n := utf8.RuneCountInString(x)
+	switch {
+	case s.MinLength != nil && n < *s.MinLength:
+		sv.fail(path, "must have a length of at least "+
+			strconv.Itoa(*s.MinLength))
+	case s.MaxLength != nil && n > *s.MaxLength:
+		sv.fail(path, "must have a length of at most "+
+			strconv.Itoa(*s.MaxLength))
+	}
+
+	switch s.Format {
+	case "email":
+		if a, err := mail.ParseAddress(x); err != nil || a.Address != x {
+			sv.fail(path, "must be an email address")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, x); err != nil {
+			sv.fail(path, "must be an RFC 3339 date-time")
+		}
+	}
+}
+
+func (sv *schemaValidator) validateNumber(s *Schema, x float64, path Pointer) {
+	switch {
+	case s.Minimum != nil && x < *s.Minimum:
+		sv.fail(path, "must be at least "+formatFloat(*s.Minimum))
+	case s.Maximum != nil && x > *s.Maximum:
+		sv.fail(path, "must be at most "+formatFloat(*s.Maximum))
+	}
+}
+
+func (sv *schemaValidator) validateArray(
+	s *Schema,
+	x []interface{},
+	path Pointer,
+) {
+	switch {
+	case s.MinItems != nil && len(x) < *s.MinItems:
+		sv.fail(path, "must have a length of at least "+
+			strconv.Itoa(*s.MinItems))
+	case s.MaxItems != nil && len(x) > *s.MaxItems:
+		sv.fail(path, "must have a length of at most "+
+			strconv.Itoa(*s.MaxItems))
+	}
+	if s.Items == nil {
+		return
+	}
+	for i, item := range x {
+		sv.validate(s.Items, item,
+			append(path[:len(path):len(path)], strconv.Itoa(i)))
+	}
+}
+
+func (sv *schemaValidator) validateObject(
+	s *Schema,
+	x map[string]interface{},
+	path Pointer,
+) {
+	for _, name := range s.Required {
+		if _, ok := x[name]; !ok {
+			sv.fail(append(path[:len(path):len(path)], name), "is required")
+		}
+	}
+	switch {
+	case s.MinProperties != nil && len(x) < *s.MinProperties:
+		sv.fail(path, "must have a length of at least "+
+			strconv.Itoa(*s.MinProperties))
+	case s.MaxProperties != nil && len(x) > *s.MaxProperties:
+		sv.fail(path, "must have a length of at most "+
+			strconv.Itoa(*s.MaxProperties))
+	}
+
+	names := make([]string, 0, len(x))
+	for name := range x {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ps, ok := s.Properties[name]
+		if !ok {
+			ps = s.AdditionalProperties
+		}
+		if ps != nil {
+			sv.validate(ps, x[name], append(path[:len(path):len(path)], name))
+		}
+	}
+}
+
+// hasSchemaType returns true if the JSON value v is of one of the types
+func hasSchemaType(types SchemaType, v interface{}) bool {
+	for _, t := range types {
+		var ok bool
+		switch t {
+		case "null":
+			ok = v == nil
+		case "boolean":
+			_, ok = v.(bool)
+		case "string":
+			_, ok = v.(string)
+		case "number":
+			_, isNumber := v.(json.Number)
+			_, isFloat := v.(float64)
+			ok = isNumber || isFloat
+		case "integer":
+			switch x := v.(type) {
+			case json.Number, float64:
+				f := toFloat(x)
+				ok = f == math.Trunc(f) && !math.IsInf(f, 0)
+			}
+		case "array":
+			_, ok = v.([]interface{})
+		case "object":
+			_, ok = v.(map[string]interface{})
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames returns the types as: a string or null
+func typeNames(types SchemaType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "null":
+			names[i] = t
+		case "array", "integer", "object":
+			names[i] = "an " + t
+		default:
+			names[i] = "a " + t
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// inEnum returns true if v is one of the values, numbers being
+// compared by their value
+func inEnum(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		switch x := v.(type) {
+		case json.Number, float64:
+			if f, ok := numberOf(e); ok && f == toFloat(x) {
+				return true
+			}
+		default:
+			if jsonEqual(e, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numberOf returns the value of the numeric enum value e
+func numberOf(e interface{}) (float64, bool) {
+	switch x := e.(type) {
+	case json.Number, float64:
+		return toFloat(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case float64:
+		return x
+	}
+	return 0
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,111 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	s := SchemaOf(&schemaEntity{})
+
+	tests := []struct {
+		name       string
+		doc        string
+		wantErrors []FieldError
+		wantErr    bool
+	}{
+		{
+			name: "valid",
+			doc: `{
+				"id": "9m4e2mr0ui3e8a215n4g", "name": "pol", "age": 30,
+				"score": "12", "email": "pol@lux.com", "level": 2,
+				"tags": ["a", "b"], "address": {"city": "lux"},
+				"labels": {"a": "b"}, "raw": [1, {}],
+				"created_at": "2020-01-02T03:04:05Z", "unknown": true
+			}`,
+		},
+		{
+			name: "null pointers",
+			doc:  `{"name": "pol", "email": null, "address": null}`,
+		},
+		{
+			name: "invalid values",
+			doc: `{
+				"name": "p", "age": 121.5, "email": "pol", "level": 4,
+				"tags": ["a"], "address": {"zip": "1"},
+				"labels": {"a": 1}, "created_at": "yesterday"
+			}`,
+			wantErrors: []FieldError{
+				{Pointer: "/address/city", Detail: "is required"},
+				{Pointer: "/address/zip", Detail: "must have a length of at least 4"},
+				{Pointer: "/age", Detail: "must be an integer"},
+				{Pointer: "/created_at", Detail: "must be an RFC 3339 date-time"},
+				{Pointer: "/email", Detail: "must be an email address"},
+				{Pointer: "/labels/a", Detail: "must be a string"},
+				{Pointer: "/level", Detail: "must be one of 1, 2, 3"},
+				{Pointer: "/name", Detail: "must have a length of at least 2"},
+				{Pointer: "/tags", Detail: "must have a length of at least 2"},
+			},
+		},
+		{
+			name: "missing fields",
+			doc:  `{"age": 200, "tags": [1, "a"]}`,
+			wantErrors: []FieldError{
+				{Pointer: "/name", Detail: "is required"},
+				{Pointer: "/address", Detail: "is required"},
+				{Pointer: "/age", Detail: "must be at most 120"},
+				{Pointer: "/tags/0", Detail: "must be a string"},
+			},
+		},
+		{
+			name: "not an object",
+			doc:  `[]`,
+			wantErrors: []FieldError{
+				{Pointer: "", Detail: "must be an object"},
+			},
+		},
+		{
+			name:    "invalid JSON",
+			doc:     `{`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateJSON([]byte(tt.doc))
+			var ve *ValidationError
+			switch {
+			case tt.wantErr:
+				if err == nil || errors.As(err, &ve) {
+					t.Errorf("ValidateJSON() error = %v, want a decode error",
+						err)
+				}
+			case tt.wantErrors == nil:
+				if err != nil {
+					t.Errorf("ValidateJSON() error = %v", err)
+				}
+			case !errors.As(err, &ve):
+				t.Errorf("ValidateJSON() error = %v, want a ValidationError",
+					err)
+			case !reflect.DeepEqual(ve.Errors, tt.wantErrors):
+				t.Errorf("ValidateJSON() errors = %v, want %v",
+					ve.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestSchemaValidateRecursive(t *testing.T) {
+	s := SchemaOf(schemaNode{})
+	err := s.ValidateJSON([]byte(
+		`{"value": 1, "children": [{"value": 2, "children": [{"value": "x"}]}]}`,
+	))
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Errors) != 1 ||
+		ve.Errors[0].Pointer != "/children/0/children/0/value" {
+		t.Errorf("ValidateJSON() error = %v", err)
+	}
+}
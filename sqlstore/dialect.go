@@ -0,0 +1,115 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL flavour of a database
+type Dialect interface {
+	// Placeholder returns the placeholder of the nth argument, from 1
+	Placeholder(n int) string
+	// Quote quotes an identifier
+	Quote(ident string) string
+	// Returning is true if INSERT ... RETURNING is supported,
+	// LastInsertId is used otherwise
+	Returning() bool
+	// Limit returns the LIMIT and OFFSET clause, limit 0 meaning none
+	Limit(limit, offset int) string
+	// ForUpdate returns the clause locking the selected rows, if any
+	ForUpdate() string
+}
+
+var (
+	// Postgres is the dialect of PostgreSQL
+	Postgres Dialect = postgres{}
+	// MySQL is the dialect of MySQL and MariaDB
+	MySQL Dialect = mysql{}
+	// SQLite is the dialect of SQLite 3.35 and later
+	SQLite Dialect = sqlite{}
+)
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgres) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (postgres) Returning() bool {
+	return true
+}
+
+func (postgres) Limit(limit, offset int) string {
+	return limitOffset(limit, offset, "")
+}
+
+func (postgres) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(int) string {
+	return "?"
+}
+
+func (mysql) Quote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+func (mysql) Returning() bool {
+	return false
+}
+
+func (mysql) Limit(limit, offset int) string {
+	// MySQL has no OFFSET without LIMIT
+	return limitOffset(limit, offset, "18446744073709551615")
+}
+
+func (mysql) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+type sqlite struct{}
+
+func (sqlite) Placeholder(int) string {
+	return "?"
+}
+
+func (sqlite) Quote(ident string) string {
+	return postgres{}.Quote(ident)
+}
+
+func (sqlite) Returning() bool {
+	return true
+}
+
+func (sqlite) Limit(limit, offset int) string {
+	// SQLite has no OFFSET without LIMIT
+	return limitOffset(limit, offset, "-1")
+}
+
+// ForUpdate is empty, SQLite locking the whole database in transactions
+func (sqlite) ForUpdate() string {
+	return ""
+}
+
+// limitOffset returns the LIMIT and OFFSET clause, with the limit all
+// for an offset without limit, if the database needs one
+func limitOffset(limit, offset int, all string) string {
+	var clause string
+	switch {
+	case limit > 0:
+		clause = " LIMIT " + strconv.Itoa(limit)
+	case offset > 0 && all != "":
+		clause = " LIMIT " + all
+	}
+	if offset > 0 {
+		clause += " OFFSET " + strconv.Itoa(offset)
+	}
+	return clause
+}
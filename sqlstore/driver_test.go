@@ -0,0 +1,175 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// exchange is a statement the fake database expects, and its result
+type exchange struct {
+	query    string
+	args     []driver.Value
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	lastID   int64
+	err      error
+}
+
+// fakeDB is a database/sql connector answering a script of exchanges,
+// in order, BEGIN, COMMIT and ROLLBACK being exchanges too
+type fakeDB struct {
+	t      *testing.T
+	mu     sync.Mutex
+	script []exchange
+}
+
+func (f *fakeDB) expect(exs ...exchange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, exs...)
+}
+
+// done fails the test if exchanges were not run
+func (f *fakeDB) done() {
+	f.t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ex := range f.script {
+		f.t.Errorf("expected %s %v", ex.query, ex.args)
+	}
+	f.script = nil
+}
+
+func (f *fakeDB) next(query string, args []driver.NamedValue) exchange {
+	f.t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	if len(f.script) == 0 {
+		f.t.Errorf("unexpected %s %v", query, values)
+		return exchange{err: errors.New("unexpected statement")}
+	}
+	ex := f.script[0]
+	f.script = f.script[1:]
+	if ex.query != query || !reflect.DeepEqual(ex.args, values) &&
+		len(ex.args)+len(values) > 0 {
+		f.t.Errorf("got %s %#v\nwant %s %#v", query, values, ex.query, ex.args)
+		return exchange{err: errors.New("unexpected statement")}
+	}
+	return ex
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{f: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{f: f}
+}
+
+type fakeDriver struct {
+	f *fakeDB
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{f: d.f}, nil
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if ex := c.f.next("BEGIN", nil); ex.err != nil {
+		return nil, ex.err
+	}
+	return fakeTx{f: c.f}, nil
+}
+
+func (c *fakeConn) ExecContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Result, error) {
+	ex := c.f.next(query, args)
+	if ex.err != nil {
+		return nil, ex.err
+	}
+	return fakeResult{ex}, nil
+}
+
+func (c *fakeConn) QueryContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Rows, error) {
+	ex := c.f.next(query, args)
+	if ex.err != nil {
+		return nil, ex.err
+	}
+	return &fakeRows{columns: ex.columns, rows: ex.rows}, nil
+}
+
+type fakeTx struct {
+	f *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	return tx.f.next("COMMIT", nil).err
+}
+
+func (tx fakeTx) Rollback() error {
+	return tx.f.next("ROLLBACK", nil).err
+}
+
+type fakeResult struct {
+	ex exchange
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.ex.lastID, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.ex.affected, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
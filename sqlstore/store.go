@@ -0,0 +1,400 @@
+// Package sqlstore is a manager storing entities in a database/sql table
+//
+// The columns are the JSON fields of the entity, named after their db tag,
+// or their JSON name, db:"-" leaving a field out, and its "id" field is the
+// primary key. Each column must be scannable from and convertible to a
+// driver value: nested structs, slices and maps need to implement
+// sql.Scanner and driver.Valuer
+//
+//	type Entity struct {
+//		ID       int64     `json:"id"`
+//		StatusID int       `json:"status_id" db:"status"`
+//		Created  time.Time `json:"created_at"`
+//		Cache    []byte    `json:"cache" db:"-"`
+//	}
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/induzo/crud"
+	"github.com/induzo/gohttperror"
+)
+
+// ErrNotFound is returned for ids of no entity
+var ErrNotFound = errors.New("entity not found")
+
+// Store is a crud.MgrI of the entities *E stored in a table,
+// its statements are parameterised, and only use the identifiers
+// of the mapping, quoted
+// It also is a crud.Counter and a crud.Exister, and lists are filtered,
+// sorted and paginated according to its crud.QuerySpec
+type Store[E any] struct {
+	db *sql.DB
+	t  *table
+	o  options
+}
+
+type options struct {
+	newID   func() crud.ID
+	parseID func(string) (crud.ID, error)
+	spec    *crud.QuerySpec
+}
+
+// Option configures a Store
+type Option func(*options)
+
+// WithIDs generates the ids with newID and parses them with parseID,
+// by default the database generates integer ids, as crud.Int64ID
+func WithIDs(
+	newID func() crud.ID,
+	parseID func(string) (crud.ID, error),
+) Option {
+	return func(o *options) {
+		o.newID = newID
+		o.parseID = parseID
+	}
+}
+
+// WithQuerySpec is the allowlist of the fields lists can be filtered and
+// sorted on, by default all the columns, contains only on strings
+func WithQuerySpec(spec crud.QuerySpec) Option {
+	return func(o *options) {
+		o.spec = &spec
+	}
+}
+
+// New returns a Store of the entities *E in the table name of db,
+// quoted as a single identifier
+// It panics if E is not a struct with an "id" JSON field and other
+// columns, or if the query spec has fields that are not columns
+func New[E any](db *sql.DB, d Dialect, name string, opts ...Option) *Store[E] {
+	o := options{
+		parseID: func(s string) (crud.ID, error) {
+			return crud.ParseInt64ID(s)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t, err := newTable(reflect.TypeOf((*E)(nil)).Elem(), name, d)
+	if err != nil {
+		panic(err)
+	}
+	if o.spec == nil {
+		o.spec = t.spec()
+	}
+	for f := range o.spec.Filterable {
+		if _, ok := t.byField[f]; !ok {
+			panic(fmt.Sprintf("sqlstore: cannot filter on %q, no column", f))
+		}
+	}
+	for _, f := range o.spec.Sortable {
+		if _, ok := t.byField[f]; !ok {
+			panic(fmt.Sprintf("sqlstore: cannot sort on %q, no column", f))
+		}
+	}
+
+	return &Store[E]{db: db, t: t, o: o}
+}
+
+// ParseID parses the ids of the store
+func (s *Store[E]) ParseID(id string) (crud.ID, error) {
+	return s.o.parseID(id)
+}
+
+// NewEmptyEntity returns a new *E
+func (s *Store[E]) NewEmptyEntity() interface{} {
+	return new(E)
+}
+
+// Create inserts e, and returns it with its new id
+func (s *Store[E]) Create(
+	ctx context.Context,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	ec, err := s.cast(e)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(ec).Elem()
+
+	if s.o.newID != nil {
+		if err := s.t.setKey(v, s.o.newID()); err != nil {
+			return nil, err
+		}
+		q, args := s.t.insert(v, true)
+		if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+			return nil, err
+		}
+		return ec, nil
+	}
+
+	q, args := s.t.insert(v, false)
+	if s.t.d.Returning() {
+		key := v.FieldByIndex(s.t.columns[s.t.key].index).Addr().Interface()
+		if err := s.db.QueryRowContext(ctx, q, args...).Scan(key); err != nil {
+			return nil, err
+		}
+		return ec, nil
+	}
+	res, err := s.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.t.setKey(v, id); err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// Get returns the entity id
+func (s *Store[E]) Get(ctx context.Context, id crud.ID) (interface{}, error) {
+	return s.get(ctx, s.db, id, false)
+}
+
+// queryer is a *sql.DB or a *sql.Tx
+type queryer interface {
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
+
+func (s *Store[E]) get(
+	ctx context.Context,
+	db queryer,
+	id crud.ID,
+	lock bool,
+) (*E, error) {
+	e := new(E)
+	q, args := s.t.selectByID(id, lock)
+	err := db.QueryRowContext(ctx, q, args...).
+		Scan(s.t.fields(reflect.ValueOf(e).Elem())...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Exists returns true if the entity id exists
+func (s *Store[E]) Exists(ctx context.Context, id crud.ID) (bool, error) {
+	return s.exists(ctx, s.db, id)
+}
+
+func (s *Store[E]) exists(
+	ctx context.Context,
+	db queryer,
+	id crud.ID,
+) (bool, error) {
+	var one int
+	q, args := s.t.exists(id)
+	err := db.QueryRowContext(ctx, q, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetList returns the entities matching the filters of lm, sorted on
+// the id after the sorts of lm
+// With a limit, the list is a *crud.Page whose cursors are the values of
+// the sorted fields of the last entity, for keyset pagination, and a bare
+// []*E otherwise; cursors need the sorted fields not to be null
+func (s *Store[E]) GetList(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (interface{}, error) {
+	q, err := crud.ParseQuery(lm, *s.o.spec)
+	if err != nil {
+		return nil, err
+	}
+	sorts := s.t.order(q.Sort)
+	var after []interface{}
+	offset := q.Offset
+	if q.Cursor != "" {
+		if after, err = s.t.decodeCursor(sorts, q.Cursor); err != nil {
+			return nil, err
+		}
+		// the cursor is past the offset already
+		offset = 0
+	}
+	limit := q.Limit
+	if limit > 0 {
+		// one more row tells if there is a next page
+		limit++
+	}
+
+	query, args, err := s.t.list(q.Filters, sorts, after, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*E{}
+	for rows.Next() {
+		e := new(E)
+		err := rows.Scan(s.t.fields(reflect.ValueOf(e).Elem())...)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if q.Limit == 0 {
+		return items, nil
+	}
+
+	p := &crud.Page{Items: items}
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+		p.Items = items
+		p.NextCursor = s.t.encodeCursor(sorts,
+			reflect.ValueOf(items[len(items)-1]).Elem())
+	}
+	return p, nil
+}
+
+// Count returns the number of entities matching the filters of lm
+func (s *Store[E]) Count(
+	ctx context.Context,
+	lm crud.ListModifiers,
+) (int64, error) {
+	q, err := crud.ParseQuery(lm, *s.o.spec)
+	if err != nil {
+		return 0, err
+	}
+	query, args, err := s.t.count(q.Filters)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = s.db.QueryRowContext(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// Update replaces the entity id by e, and returns it
+func (s *Store[E]) Update(
+	ctx context.Context,
+	id crud.ID,
+	e interface{},
+	pl io.Reader,
+) (interface{}, error) {
+	ec, err := s.cast(e)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.update(ctx, s.db, id, ec); err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// update updates the entity id to e, setting its id
+func (s *Store[E]) update(
+	ctx context.Context,
+	db queryer,
+	id crud.ID,
+	e *E,
+) error {
+	v := reflect.ValueOf(e).Elem()
+	if err := s.t.setKey(v, id); err != nil {
+		return err
+	}
+	q, args := s.t.update(id, v)
+	res, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	// MySQL doesn't count the rows left unchanged
+	ok, err := s.exists(ctx, db, id)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// PartialUpdate merges pud into the entity id, in a transaction
+// locking its row
+func (s *Store[E]) PartialUpdate(
+	ctx context.Context,
+	id crud.ID,
+	pud crud.PartialUpdateData,
+	pl io.Reader,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	e, err := s.get(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+	target := new(E)
+	if err := pud.ApplyTo(e, target); err != nil {
+		return err
+	}
+	if err := s.update(ctx, tx, id, target); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete deletes the entity id
+func (s *Store[E]) Delete(ctx context.Context, id crud.ID) error {
+	q, args := s.t.delete(id)
+	res, err := s.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// MapErrorToHTTPError maps ErrNotFound to 404,
+// and the database errors to 500
+func (s *Store[E]) MapErrorToHTTPError(e error) *gohttperror.ErrResponse {
+	switch {
+	case errors.Is(e, ErrNotFound):
+		return gohttperror.ErrNotFound
+	case errors.Is(e, crud.ErrEntityType):
+		return gohttperror.ErrBadRequest(e)
+	default:
+		return gohttperror.ErrInternal(e)
+	}
+}
+
+func (s *Store[E]) cast(e interface{}) (*E, error) {
+	ec, ok := e.(*E)
+	if !ok || ec == nil {
+		return nil, fmt.Errorf("%w: got %T, want %T", crud.ErrEntityType, e, ec)
+	}
+	return ec, nil
+}
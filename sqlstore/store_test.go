@@ -0,0 +1,250 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/induzo/crud"
+	"github.com/rs/xid"
+)
+
+var _ interface {
+	crud.MgrI
+	crud.Counter
+	crud.Exister
+	crud.IDParser
+} = (*Store[row])(nil)
+
+var rowColumns = []string{"id", "name", "status", "deleted_at"}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeDB{t: t}
+	s := New[row](sql.OpenDB(f), Postgres, "items")
+	const selectRow = `SELECT "id", "name", "status", "deleted_at" ` +
+		`FROM "items" WHERE "id" = $1`
+
+	f.expect(exchange{
+		query: `INSERT INTO "items" ("name", "status", "deleted_at") ` +
+			`VALUES ($1, $2, $3) RETURNING "id"`,
+		args:    []driver.Value{"pen", int64(1), nil},
+		columns: []string{"id"},
+		rows:    [][]driver.Value{{int64(7)}},
+	})
+	e, err := s.Create(ctx, &row{ID: 99, Name: "pen", Status: 1}, nil)
+	if err != nil || e.(*row).ID != 7 {
+		t.Errorf("Create() = %+v, %v", e, err)
+	}
+	f.done()
+
+	f.expect(exchange{
+		query:   selectRow,
+		args:    []driver.Value{int64(7)},
+		columns: rowColumns,
+		rows:    [][]driver.Value{{int64(7), "pen", int64(1), nil}},
+	}, exchange{
+		query:   selectRow,
+		args:    []driver.Value{int64(8)},
+		columns: rowColumns,
+	})
+	e, err = s.Get(ctx, crud.Int64ID(7))
+	if err != nil || e.(*row).Name != "pen" || e.(*row).Deleted != nil {
+		t.Errorf("Get() = %+v, %v", e, err)
+	}
+	if _, err := s.Get(ctx, crud.Int64ID(8)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing entity error = %v", err)
+	}
+	f.done()
+
+	f.expect(exchange{
+		query: `SELECT "id", "name", "status", "deleted_at" FROM "items" ` +
+			`WHERE "status" = $1 ORDER BY "name" DESC, "id" LIMIT 2`,
+		args:    []driver.Value{int64(1)},
+		columns: rowColumns,
+		rows: [][]driver.Value{
+			{int64(7), "pen", int64(1), nil},
+			{int64(3), "ink", int64(1), nil},
+		},
+	})
+	list, err := s.GetList(ctx, crud.ListModifiers{
+		"status_id": {"1"}, "orderby": {"name DESC"}, "limit": {"1"},
+	})
+	if err != nil {
+		t.Fatalf("GetList() error = %v", err)
+	}
+	p := list.(*crud.Page)
+	if items := p.Items.([]*row); len(items) != 1 ||
+		p.NextCursor != `["pen",7]` {
+		t.Errorf("GetList() = %+v, cursor %s", items, p.NextCursor)
+	}
+	f.done()
+
+	// the next page of an offset page only follows the cursor
+	f.expect(exchange{
+		query: `SELECT "id", "name", "status", "deleted_at" FROM "items" ` +
+			`ORDER BY "id" LIMIT 2 OFFSET 10`,
+		columns: rowColumns,
+		rows: [][]driver.Value{
+			{int64(11), "pen", int64(1), nil},
+			{int64(12), "ink", int64(1), nil},
+		},
+	}, exchange{
+		query: `SELECT "id", "name", "status", "deleted_at" FROM "items" ` +
+			`WHERE (("id" > $1)) ORDER BY "id" LIMIT 2`,
+		args:    []driver.Value{int64(11)},
+		columns: rowColumns,
+		rows:    [][]driver.Value{{int64(12), "ink", int64(1), nil}},
+	})
+	lm := crud.ListModifiers{"limit": {"1"}, "offset": {"10"}}
+	list, err = s.GetList(ctx, lm)
+	if err != nil {
+		t.Fatalf("GetList() with an offset error = %v", err)
+	}
+	lm["page_token"] = []string{list.(*crud.Page).NextCursor}
+	list, err = s.GetList(ctx, lm)
+	if err != nil || len(list.(*crud.Page).Items.([]*row)) != 1 {
+		t.Errorf("GetList() of the next page = %+v, %v", list, err)
+	}
+	f.done()
+
+	f.expect(exchange{
+		query:   `SELECT COUNT(*) FROM "items" WHERE "name" <> $1`,
+		args:    []driver.Value{"pen"},
+		columns: []string{"count"},
+		rows:    [][]driver.Value{{int64(4)}},
+	})
+	n, err := s.Count(ctx, crud.ListModifiers{"name[ne]": {"pen"}})
+	if err != nil || n != 4 {
+		t.Errorf("Count() = %d, %v", n, err)
+	}
+	f.done()
+
+	f.expect(exchange{
+		query: `UPDATE "items" SET "name" = $1, "status" = $2, ` +
+			`"deleted_at" = $3 WHERE "id" = $4`,
+		args: []driver.Value{"ink", int64(2), nil, int64(8)},
+	}, exchange{
+		query:   `SELECT 1 FROM "items" WHERE "id" = $1`,
+		args:    []driver.Value{int64(8)},
+		columns: []string{"1"},
+	})
+	_, err = s.Update(ctx, crud.Int64ID(8), &row{Name: "ink", Status: 2}, nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() of a missing entity error = %v", err)
+	}
+	f.done()
+
+	f.expect(exchange{query: "BEGIN"}, exchange{
+		query:   selectRow + " FOR UPDATE",
+		args:    []driver.Value{int64(7)},
+		columns: rowColumns,
+		rows:    [][]driver.Value{{int64(7), "pen", int64(1), nil}},
+	}, exchange{
+		query: `UPDATE "items" SET "name" = $1, "status" = $2, ` +
+			`"deleted_at" = $3 WHERE "id" = $4`,
+		args:     []driver.Value{"pen", int64(3), nil, int64(7)},
+		affected: 1,
+	}, exchange{query: "COMMIT"})
+	err = s.PartialUpdate(ctx, crud.Int64ID(7),
+		crud.PartialUpdateData{"status_id": 3}, nil)
+	if err != nil {
+		t.Errorf("PartialUpdate() error = %v", err)
+	}
+	f.done()
+
+	f.expect(exchange{query: "BEGIN"}, exchange{
+		query:   selectRow + " FOR UPDATE",
+		args:    []driver.Value{int64(7)},
+		columns: rowColumns,
+		rows:    [][]driver.Value{{int64(7), "pen", int64(1), nil}},
+	}, exchange{query: "ROLLBACK"})
+	err = s.PartialUpdate(ctx, crud.Int64ID(7),
+		crud.PartialUpdateData{"nope": "x"}, nil)
+	if !errors.Is(err, crud.ErrInvalidPatch) {
+		t.Errorf("PartialUpdate() of an unknown field error = %v", err)
+	}
+	f.done()
+
+	f.expect(exchange{
+		query: `DELETE FROM "items" WHERE "id" = $1`,
+		args:  []driver.Value{int64(8)},
+	})
+	if err := s.Delete(ctx, crud.Int64ID(8)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of a missing entity error = %v", err)
+	}
+	f.done()
+}
+
+func TestStoreCreate(t *testing.T) {
+	ctx := context.Background()
+
+	type xrow struct {
+		ID   xid.ID `json:"id"`
+		Name string `json:"name"`
+	}
+	id := xid.New()
+	f := &fakeDB{t: t}
+	s := New[xrow](sql.OpenDB(f), SQLite, "items", WithIDs(
+		func() crud.ID { return id },
+		func(s string) (crud.ID, error) { return crud.ParseXID(s) },
+	))
+	f.expect(exchange{
+		query: `INSERT INTO "items" ("id", "name") VALUES (?, ?)`,
+		args:  []driver.Value{id.String(), "pen"},
+	})
+	e, err := s.Create(ctx, &xrow{Name: "pen"}, nil)
+	if err != nil || e.(*xrow).ID != id {
+		t.Errorf("Create() with generated ids = %+v, %v", e, err)
+	}
+	f.done()
+
+	f = &fakeDB{t: t}
+	m := New[row](sql.OpenDB(f), MySQL, "items")
+	f.expect(exchange{
+		query: "INSERT INTO `items` (`name`, `status`, `deleted_at`) " +
+			"VALUES (?, ?, ?)",
+		args:   []driver.Value{"pen", int64(0), nil},
+		lastID: 5,
+	})
+	e, err = m.Create(ctx, &row{Name: "pen"}, nil)
+	if err != nil || e.(*row).ID != 5 {
+		t.Errorf("Create() with LastInsertId = %+v, %v", e, err)
+	}
+	f.done()
+}
+
+func TestNewPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func()
+	}{
+		{
+			name: "no id",
+			new: func() {
+				New[struct{ Name string }](nil, Postgres, "t")
+			},
+		},
+		{
+			name: "unknown sortable field",
+			new: func() {
+				New[row](nil, Postgres, "t", WithQuerySpec(crud.QuerySpec{
+					Sortable: []string{"cache"},
+				}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("New() didn't panic")
+				}
+			}()
+			tt.new()
+		})
+	}
+}
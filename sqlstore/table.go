@@ -0,0 +1,446 @@
+package sqlstore
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/induzo/crud"
+)
+
+// column is a field of the entity stored in a column
+type column struct {
+	// name is the column name
+	name string
+	// field is the JSON name of the field
+	field string
+	index []int
+	typ   reflect.Type
+}
+
+// table maps the entities to the rows of a table
+type table struct {
+	name    string
+	d       Dialect
+	columns []column
+	key     int
+	byField map[string]int
+}
+
+// newTable maps the JSON fields of the struct type t to columns,
+// named after their db tag, or their JSON name, db:"-" skipping a field
+// The "id" JSON field is the primary key
+func newTable(t reflect.Type, name string, d Dialect) (*table, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlstore: %v is not a struct", t)
+	}
+
+	tb := &table{name: name, d: d, key: -1, byField: map[string]int{}}
+	for _, f := range crud.JSONFields(t) {
+		col, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if col == "-" {
+			continue
+		}
+		if col == "" {
+			col = f.Name
+		}
+		if f.Name == "id" {
+			tb.key = len(tb.columns)
+		}
+		tb.byField[f.Name] = len(tb.columns)
+		tb.columns = append(tb.columns, column{
+			name:  col,
+			field: f.Name,
+			index: f.Index,
+			typ:   f.Type,
+		})
+	}
+	if tb.key < 0 {
+		return nil, fmt.Errorf("sqlstore: %v has no id field", t)
+	}
+	// updates would set nothing
+	if len(tb.columns) == 1 {
+		return nil, fmt.Errorf("sqlstore: %v has no column but its id", t)
+	}
+	return tb, nil
+}
+
+// spec allows filtering and sorting on all the columns,
+// contains being only allowed on strings
+func (tb *table) spec() *crud.QuerySpec {
+	spec := &crud.QuerySpec{Filterable: map[string][]crud.Operator{}}
+	for _, c := range tb.columns {
+		spec.Filterable[c.field] = nil
+		if elem(c.typ).Kind() != reflect.String {
+			spec.Filterable[c.field] = []crud.Operator{
+				crud.Eq, crud.Ne, crud.Gt, crud.Gte, crud.Lt, crud.Lte, crud.In,
+			}
+		}
+		spec.Sortable = append(spec.Sortable, c.field)
+	}
+	return spec
+}
+
+// builder builds a statement and its arguments
+type builder struct {
+	strings.Builder
+	d    Dialect
+	args []interface{}
+}
+
+// arg adds the argument v, and returns its placeholder
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return b.d.Placeholder(len(b.args))
+}
+
+func (tb *table) quote(col int) string {
+	return tb.d.Quote(tb.columns[col].name)
+}
+
+func (tb *table) columnList() string {
+	cols := make([]string, len(tb.columns))
+	for i := range tb.columns {
+		cols[i] = tb.quote(i)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// fields returns the addresses of the fields of the entity v,
+// to scan a row into
+func (tb *table) fields(v reflect.Value) []interface{} {
+	dest := make([]interface{}, len(tb.columns))
+	for i, c := range tb.columns {
+		dest[i] = v.FieldByIndex(c.index).Addr().Interface()
+	}
+	return dest
+}
+
+// selectByID selects the entity id, locking it if lock
+func (tb *table) selectByID(id crud.ID, lock bool) (string, []interface{}) {
+	b := &builder{d: tb.d}
+	fmt.Fprintf(b, "SELECT %s FROM %s WHERE %s = %s",
+		tb.columnList(), tb.d.Quote(tb.name), tb.quote(tb.key), b.arg(id))
+	if lock {
+		b.WriteString(tb.d.ForUpdate())
+	}
+	return b.String(), b.args
+}
+
+// exists selects 1 if the entity id exists
+func (tb *table) exists(id crud.ID) (string, []interface{}) {
+	b := &builder{d: tb.d}
+	fmt.Fprintf(b, "SELECT 1 FROM %s WHERE %s = %s",
+		tb.d.Quote(tb.name), tb.quote(tb.key), b.arg(id))
+	return b.String(), b.args
+}
+
+// insert inserts the entity v, without its key unless withKey,
+// returning the key if the dialect can
+func (tb *table) insert(v reflect.Value, withKey bool) (string, []interface{}) {
+	b := &builder{d: tb.d}
+	cols := make([]string, 0, len(tb.columns))
+	values := make([]string, 0, len(tb.columns))
+	for i, c := range tb.columns {
+		if i == tb.key && !withKey {
+			continue
+		}
+		cols = append(cols, tb.quote(i))
+		values = append(values, b.arg(v.FieldByIndex(c.index).Interface()))
+	}
+	fmt.Fprintf(b, "INSERT INTO %s (%s) VALUES (%s)", tb.d.Quote(tb.name),
+		strings.Join(cols, ", "), strings.Join(values, ", "))
+	if !withKey && tb.d.Returning() {
+		b.WriteString(" RETURNING " + tb.quote(tb.key))
+	}
+	return b.String(), b.args
+}
+
+// update sets all the columns of the entity id but its key
+// to the fields of v
+func (tb *table) update(id crud.ID, v reflect.Value) (string, []interface{}) {
+	b := &builder{d: tb.d}
+	sets := make([]string, 0, len(tb.columns))
+	for i, c := range tb.columns {
+		if i == tb.key {
+			continue
+		}
+		sets = append(sets,
+			tb.quote(i)+" = "+b.arg(v.FieldByIndex(c.index).Interface()))
+	}
+	fmt.Fprintf(b, "UPDATE %s SET %s WHERE %s = %s", tb.d.Quote(tb.name),
+		strings.Join(sets, ", "), tb.quote(tb.key), b.arg(id))
+	return b.String(), b.args
+}
+
+// delete deletes the entity id
+func (tb *table) delete(id crud.ID) (string, []interface{}) {
+	b := &builder{d: tb.d}
+	fmt.Fprintf(b, "DELETE FROM %s WHERE %s = %s",
+		tb.d.Quote(tb.name), tb.quote(tb.key), b.arg(id))
+	return b.String(), b.args
+}
+
+// count counts the entities matching the filters
+func (tb *table) count(filters []crud.Filter) (string, []interface{}, error) {
+	b := &builder{d: tb.d}
+	fmt.Fprintf(b, "SELECT COUNT(*) FROM %s", tb.d.Quote(tb.name))
+	conds, err := tb.where(b, filters)
+	if err != nil {
+		return "", nil, err
+	}
+	writeWhere(b, conds)
+	return b.String(), b.args, nil
+}
+
+// list selects the entities matching the filters, ordered by sorts,
+// after the values of the sorted fields if any, and paginated
+func (tb *table) list(
+	filters []crud.Filter,
+	sorts []crud.Sort,
+	after []interface{},
+	limit, offset int,
+) (string, []interface{}, error) {
+	b := &builder{d: tb.d}
+	fmt.Fprintf(b, "SELECT %s FROM %s", tb.columnList(), tb.d.Quote(tb.name))
+	conds, err := tb.where(b, filters)
+	if err != nil {
+		return "", nil, err
+	}
+	if after != nil {
+		conds = append(conds, tb.keyset(b, sorts, after))
+	}
+	writeWhere(b, conds)
+
+	order := make([]string, len(sorts))
+	for i, s := range sorts {
+		order[i] = tb.quote(tb.byField[s.Field])
+		if s.Desc {
+			order[i] += " DESC"
+		}
+	}
+	b.WriteString(" ORDER BY " + strings.Join(order, ", "))
+	b.WriteString(tb.d.Limit(limit, offset))
+	return b.String(), b.args, nil
+}
+
+func writeWhere(b *builder, conds []string) {
+	if len(conds) > 0 {
+		b.WriteString(" WHERE " + strings.Join(conds, " AND "))
+	}
+}
+
+// where returns the conditions of the filters
+func (tb *table) where(b *builder, filters []crud.Filter) ([]string, error) {
+	conds := make([]string, 0, len(filters))
+	for _, f := range filters {
+		i, ok := tb.byField[f.Field]
+		if !ok {
+			return nil, &crud.QueryError{
+				Param:  f.Field,
+				Reason: "unknown field",
+			}
+		}
+		col := tb.quote(i)
+		c := tb.columns[i]
+
+		if f.Value() == "null" && c.typ.Kind() == reflect.Ptr {
+			switch f.Operator {
+			case crud.Eq:
+				conds = append(conds, col+" IS NULL")
+				continue
+			case crud.Ne:
+				conds = append(conds, col+" IS NOT NULL")
+				continue
+			}
+		}
+		if f.Operator == crud.Contains {
+			conds = append(conds,
+				col+" LIKE "+b.arg("%"+likeEscaper.Replace(f.Value())+"%")+
+					" ESCAPE '!'")
+			continue
+		}
+
+		values := make([]interface{}, len(f.Values))
+		for j, s := range f.Values {
+			v, err := parseValue(c, s)
+			if err != nil {
+				return nil, err
+			}
+			values[j] = v
+		}
+		if f.Operator == crud.In {
+			phs := make([]string, len(values))
+			for j, v := range values {
+				phs[j] = b.arg(v)
+			}
+			conds = append(conds, col+" IN ("+strings.Join(phs, ", ")+")")
+			continue
+		}
+		op, ok := operators[f.Operator]
+		if !ok {
+			return nil, &crud.QueryError{
+				Param:  f.Field,
+				Reason: fmt.Sprintf("operator %q not supported", f.Operator),
+			}
+		}
+		conds = append(conds, col+" "+op+" "+b.arg(values[0]))
+	}
+	return conds, nil
+}
+
+var operators = map[crud.Operator]string{
+	crud.Eq:  "=",
+	crud.Ne:  "<>",
+	crud.Gt:  ">",
+	crud.Gte: ">=",
+	crud.Lt:  "<",
+	crud.Lte: "<=",
+}
+
+// likeEscaper escapes the LIKE wildcards, with ! as the escape character
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// keyset returns the condition of the rows coming after the values of
+// the sorted fields: a > ? OR (a = ? AND b > ?) ...
+func (tb *table) keyset(
+	b *builder,
+	sorts []crud.Sort,
+	after []interface{},
+) string {
+	ors := make([]string, len(sorts))
+	for i, s := range sorts {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, tb.quote(tb.byField[sorts[j].Field])+
+				" = "+b.arg(after[j]))
+		}
+		op := " > "
+		if s.Desc {
+			op = " < "
+		}
+		ands = append(ands,
+			tb.quote(tb.byField[s.Field])+op+b.arg(after[i]))
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+// order returns the sorts, ending with the key so that the order is total
+func (tb *table) order(sorts []crud.Sort) []crud.Sort {
+	key := tb.columns[tb.key].field
+	for _, s := range sorts {
+		if s.Field == key {
+			return sorts
+		}
+	}
+	return append(sorts[:len(sorts):len(sorts)], crud.Sort{Field: key})
+}
+
+// encodeCursor returns the cursor of the rows after the entity v,
+// the JSON array of its values of the sorted fields
+func (tb *table) encodeCursor(sorts []crud.Sort, v reflect.Value) string {
+	values := make([]interface{}, len(sorts))
+	for i, s := range sorts {
+		c := tb.columns[tb.byField[s.Field]]
+		values[i] = v.FieldByIndex(c.index).Interface()
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// decodeCursor returns the values of the sorted fields of a cursor
+func (tb *table) decodeCursor(
+	sorts []crud.Sort,
+	cursor string,
+) ([]interface{}, error) {
+	invalid := &crud.QueryError{
+		Param:  crud.ParamPageToken,
+		Reason: "unknown cursor",
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(cursor), &raw); err != nil ||
+		len(raw) != len(sorts) {
+		return nil, invalid
+	}
+	values := make([]interface{}, len(sorts))
+	for i, s := range sorts {
+		c := tb.columns[tb.byField[s.Field]]
+		v := reflect.New(c.typ)
+		if err := json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, invalid
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
+
+// setKey sets the key field of the entity v to id
+func (tb *table) setKey(v reflect.Value, id interface{}) error {
+	f := v.FieldByIndex(tb.columns[tb.key].index)
+	iv := reflect.ValueOf(id)
+	switch {
+	case iv.Type().AssignableTo(f.Type()):
+		f.Set(iv)
+	case f.Kind() == reflect.String:
+		f.SetString(fmt.Sprint(id))
+	case iv.Type().ConvertibleTo(f.Type()) && iv.Kind() != reflect.String:
+		f.Set(iv.Convert(f.Type()))
+	default:
+		return fmt.Errorf("sqlstore: cannot set a %T id to a %v field",
+			id, f.Type())
+	}
+	return nil
+}
+
+// parseValue parses the filter value s as a value of the column c
+func parseValue(c column, s string) (interface{}, error) {
+	t := elem(c.typ)
+	v := reflect.New(t)
+	var err error
+	if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		err = u.UnmarshalText([]byte(s))
+	} else {
+		switch t.Kind() {
+		case reflect.String:
+			v.Elem().SetString(s)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(s)
+			v.Elem().SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, t.Bits())
+			v.Elem().SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64:
+			var n uint64
+			n, err = strconv.ParseUint(s, 10, t.Bits())
+			v.Elem().SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			var n float64
+			n, err = strconv.ParseFloat(s, t.Bits())
+			v.Elem().SetFloat(n)
+		default:
+			return s, nil
+		}
+	}
+	if err != nil {
+		return nil, &crud.QueryError{
+			Param:  c.field,
+			Reason: fmt.Sprintf("%q is not a valid value", s),
+		}
+	}
+	return v.Elem().Interface(), nil
+}
+
+func elem(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,173 @@
+package sqlstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/induzo/crud"
+)
+
+type row struct {
+	ID      int64      `json:"id"`
+	Name    string     `json:"name"`
+	Status  int        `json:"status_id" db:"status"`
+	Deleted *time.Time `json:"deleted_at"`
+	Cache   string     `json:"cache" db:"-"`
+	Secret  string     `json:"-"`
+}
+
+func TestTableList(t *testing.T) {
+	tests := []struct {
+		name     string
+		d        Dialect
+		lm       crud.ListModifiers
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name: "postgres",
+			d:    Postgres,
+			lm: crud.ListModifiers{
+				"name[contains]": {"a_b"},
+				"status_id[in]":  {"1,2"},
+				"orderby":        {"status_id DESC"},
+				"limit":          {"10"},
+			},
+			wantSQL: `SELECT "id", "name", "status", "deleted_at" ` +
+				`FROM "items" WHERE "name" LIKE $1 ESCAPE '!' ` +
+				`AND "status" IN ($2, $3) ` +
+				`ORDER BY "status" DESC, "id" LIMIT 10`,
+			wantArgs: []interface{}{"%a!_b%", 1, 2},
+		},
+		{
+			name: "mysql",
+			d:    MySQL,
+			lm: crud.ListModifiers{
+				"deleted_at": {"null"},
+				"offset":     {"5"},
+			},
+			wantSQL: "SELECT `id`, `name`, `status`, `deleted_at` " +
+				"FROM `items` WHERE `deleted_at` IS NULL " +
+				"ORDER BY `id` LIMIT 18446744073709551615 OFFSET 5",
+		},
+		{
+			name: "sqlite with a cursor",
+			d:    SQLite,
+			lm: crud.ListModifiers{
+				"status_id[gte]": {"1"},
+				"orderby":        {"name"},
+				"page_token":     {`["b",4]`},
+				"limit":          {"2"},
+			},
+			wantSQL: `SELECT "id", "name", "status", "deleted_at" ` +
+				`FROM "items" WHERE "status" >= ? ` +
+				`AND (("name" > ?) OR ("name" = ? AND "id" > ?)) ` +
+				`ORDER BY "name", "id" LIMIT 2`,
+			wantArgs: []interface{}{1, "b", "b", int64(4)},
+		},
+		{
+			name: "time",
+			d:    Postgres,
+			lm: crud.ListModifiers{
+				"deleted_at[lt]": {"2021-01-01T00:00:00Z"},
+			},
+			wantSQL: `SELECT "id", "name", "status", "deleted_at" ` +
+				`FROM "items" WHERE "deleted_at" < $1 ORDER BY "id"`,
+			wantArgs: []interface{}{
+				time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "invalid value",
+			d:       Postgres,
+			lm:      crud.ListModifiers{"status_id": {"x"}},
+			wantErr: true,
+		},
+		{
+			name:    "contains on a number",
+			d:       Postgres,
+			lm:      crud.ListModifiers{"status_id[contains]": {"1"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid cursor",
+			d:       Postgres,
+			lm:      crud.ListModifiers{"page_token": {`["b"]`}},
+			wantErr: true,
+		},
+		{
+			name:    "not a column",
+			d:       Postgres,
+			lm:      crud.ListModifiers{"cache": {"x"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb, err := newTable(reflect.TypeOf(row{}), "items", tt.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql, args, err := func() (string, []interface{}, error) {
+				q, err := crud.ParseQuery(tt.lm, *tb.spec())
+				if err != nil {
+					return "", nil, err
+				}
+				sorts := tb.order(q.Sort)
+				var after []interface{}
+				if q.Cursor != "" {
+					after, err = tb.decodeCursor(sorts, q.Cursor)
+					if err != nil {
+						return "", nil, err
+					}
+				}
+				return tb.list(q.Filters, sorts, after, q.Limit, q.Offset)
+			}()
+
+			if tt.wantErr {
+				var qe *crud.QueryError
+				if !errors.As(err, &qe) {
+					t.Errorf("list() error = %v, want a QueryError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("list() error = %v", err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("list() =\n%s\nwant\n%s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("list() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	if _, err := newTable(reflect.TypeOf(struct {
+		Name string `json:"name"`
+	}{}), "t", Postgres); err == nil {
+		t.Errorf("newTable() of a struct without id didn't fail")
+	}
+	if _, err := newTable(reflect.TypeOf(struct {
+		ID    int64  `json:"id"`
+		Cache string `json:"cache" db:"-"`
+	}{}), "t", Postgres); err == nil {
+		t.Errorf("newTable() of a struct with only an id didn't fail")
+	}
+
+	tb, err := newTable(reflect.TypeOf(row{}), `we"ird`, Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql, _ := tb.delete(crud.Int64ID(1)); sql !=
+		`DELETE FROM "we""ird" WHERE "id" = $1` {
+		t.Errorf("delete() = %s", sql)
+	}
+}
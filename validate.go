@@ -0,0 +1,324 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator is an entity able to validate itself,
+// it can return a *ValidationError to point at the invalid fields
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is the error of a field, identified by a JSON pointer
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+// ValidationError lists the invalid fields of an entity,
+// it wraps ErrUnprocessableEntity
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = strings.TrimSpace(fe.Pointer + " " + fe.Detail)
+	}
+	return "invalid entity: " + strings.Join(msgs, ", ")
+}
+
+// Unwrap returns ErrUnprocessableEntity
+func (e *ValidationError) Unwrap() error {
+	return ErrUnprocessableEntity
+}
+
+// Validate checks the validate struct tags of v, and calls the Validate
+// method of v and of its nested values implementing Validator
+// It returns a *ValidationError listing the invalid fields
+//
+// The rules of a validate tag are separated by commas:
+//
+//	required   not the zero value, not empty for slices and maps
+//	omitempty  skips the other rules for the zero value
+//	min=n      at least n for numbers, n long for strings, slices and maps
+//	max=n      at most n for numbers, n long for strings, slices and maps
+//	len=n      exactly n long for strings, slices and maps
+//	oneof=a b  one of the space separated values
+//	email      an email address
+func Validate(v interface{}) error {
+	var errs []FieldError
+	if err := validateValue(reflect.ValueOf(v), Pointer{}, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// HasValidation returns true if Validate can fail for the type of v,
+// because it has validate tags or implements Validator
+func HasValidation(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if has, ok := hasValidationMap.Load(t); ok {
+		return has.(bool)
+	}
+	has := hasValidation(t, map[reflect.Type]bool{})
+	hasValidationMap.Store(t, has)
+	return has
+}
+
+var (
+	validatorType    = reflect.TypeOf((*Validator)(nil)).Elem()
+	hasValidationMap sync.Map
+)
+
+// hasValidation returns true if t has validations, seen being the types
+// evaluated so far, whose results are only cached by HasValidation, as
+// they are false in a cycle until its type is done
+func hasValidation(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == nil || seen[t] {
+		return false
+	}
+	if has, ok := hasValidationMap.Load(t); ok {
+		return has.(bool)
+	}
+	seen[t] = true
+
+	has := t.Implements(validatorType) ||
+		reflect.PtrTo(t).Implements(validatorType)
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		has = has || hasValidation(t.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range JSONFields(t) {
+			has = has || f.Tag.Get("validate") != "" ||
+				hasValidation(f.Type, seen)
+		}
+	}
+
+	return has
+}
+
+func validateValue(v reflect.Value, path Pointer, errs *[]FieldError) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range JSONFields(v.Type()) {
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				// through a nil embedded pointer
+				continue
+			}
+			p := append(path[:len(path):len(path)], f.Name)
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if err := checkRules(fv, tag, p, errs); err != nil {
+					return err
+				}
+			}
+			if err := validateValue(fv, p, errs); err != nil {
+				return err
+			}
+		}
+		callValidator(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			p := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if err := validateValue(v.Index(i), p, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			p := append(path[:len(path):len(path)], fmt.Sprint(k))
+			if err := validateValue(v.MapIndex(k), p, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// callValidator adds the errors of the Validate method of v, if any,
+// under path
+func callValidator(v reflect.Value, path Pointer, errs *[]FieldError) {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	val, ok := v.Interface().(Validator)
+	if !ok {
+		return
+	}
+	err := val.Validate()
+	if err == nil {
+		return
+	}
+
+	prefix := path.String()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		*errs = append(*errs, FieldError{Pointer: prefix, Detail: err.Error()})
+		return
+	}
+	for _, fe := range ve.Errors {
+		fe.Pointer = prefix + fe.Pointer
+		*errs = append(*errs, fe)
+	}
+}
+
+// checkRules adds the errors of the rules of tag for v,
+// it returns an error for invalid rules
+func checkRules(
+	v reflect.Value,
+	tag string,
+	path Pointer,
+	errs *[]FieldError,
+) error {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "omitempty" {
+			if isEmpty(v) {
+				return nil
+			}
+			continue
+		}
+		detail, err := checkRule(v, name, arg)
+		if err != nil {
+			return fmt.Errorf("crud: validate tag of %s: %w", path.String(), err)
+		}
+		if detail != "" {
+			*errs = append(*errs, FieldError{
+				Pointer: path.String(),
+				Detail:  detail,
+			})
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkRule returns the detail of the error of the rule for v,
+// an empty string if v is valid
+// Only required applies to nil pointers
+func checkRule(v reflect.Value, name, arg string) (string, error) {
+	switch name {
+	case "required":
+		if isEmpty(v) {
+			return "is required", nil
+		}
+		return "", nil
+	case "min", "max", "len", "oneof", "email":
+	default:
+		return "", fmt.Errorf("unknown rule %q", name)
+	}
+
+	if v = indirect(v); !v.IsValid() {
+		return "", nil
+	}
+	switch name {
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, o := range strings.Fields(arg) {
+			if s == o {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(arg), ", "), nil
+	case "email":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("email of a %s", v.Kind())
+		}
+		if a, err := mail.ParseAddress(v.String()); err != nil ||
+			a.Address != v.String() {
+			return "must be an email address", nil
+		}
+		return "", nil
+	default:
+		return checkBound(v, name, arg)
+	}
+}
+
+func checkBound(v reflect.Value, name, arg string) (string, error) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	var x float64
+	length := true
+	switch v.Kind() {
+	case reflect.String:
+		x = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		x = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		x, length = float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		x, length = float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		x, length = v.Float(), false
+	default:
+		return "", fmt.Errorf("%s of a %s", name, v.Kind())
+	}
+	if name == "len" && !length {
+		return "", fmt.Errorf("len of a %s", v.Kind())
+	}
+
+	prefix := "must be"
+	if length {
+		prefix = "must have a length of"
+	}
+	switch {
+	case name == "min" && x < n:
+		return fmt.Sprintf("%s at least %s", prefix, arg), nil
+	case name == "max" && x > n:
+		return fmt.Sprintf("%s at most %s", prefix, arg), nil
+	case name == "len" && x != n:
+		return fmt.Sprintf("%s %s", prefix, arg), nil
+	}
+	return "", nil
+}
+
+// indirect follows the pointers of v, up to a nil one
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmpty returns true for zero values, empty slices and maps
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
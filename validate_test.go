@@ -0,0 +1,151 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type validAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip,omitempty" validate:"omitempty,len=4"`
+}
+
+type validEntity struct {
+	Name    string            `json:"name" validate:"required,min=2,max=5"`
+	Age     int               `json:"age" validate:"min=18"`
+	Email   *string           `json:"email" validate:"email"`
+	Role    string            `json:"role" validate:"oneof=admin user"`
+	Tags    []string          `json:"tags" validate:"max=2"`
+	Address *validAddress     `json:"address" validate:"required"`
+	Others  []validAddress    `json:"others"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func (e *validEntity) Validate() error {
+	if e.Name == "admin" && e.Role != "admin" {
+		return &ValidationError{Errors: []FieldError{
+			{Pointer: "/role", Detail: "must be admin"},
+		}}
+	}
+	return nil
+}
+
+type validBadTag struct {
+	Name string `json:"name" validate:"bigger=1"`
+}
+
+type validPlain struct {
+	Name string `json:"name"`
+}
+
+func TestValidate(t *testing.T) {
+	email := "pol@lux.com"
+	badEmail := "pol"
+
+	tests := []struct {
+		name       string
+		v          interface{}
+		wantErrors []FieldError
+		wantErr    bool
+	}{
+		{
+			name: "valid",
+			v: &validEntity{
+				Name: "pol", Age: 18, Email: &email, Role: "user",
+				Address: &validAddress{City: "lux", Zip: "1234"},
+			},
+		},
+		{
+			name: "invalid fields",
+			v: &validEntity{
+				Name: "p", Age: 17, Email: &badEmail, Role: "root",
+				Tags: []string{"a", "b", "c"},
+			},
+			wantErrors: []FieldError{
+				{Pointer: "/name", Detail: "must have a length of at least 2"},
+				{Pointer: "/age", Detail: "must be at least 18"},
+				{Pointer: "/email", Detail: "must be an email address"},
+				{Pointer: "/role", Detail: "must be one of admin, user"},
+				{Pointer: "/tags", Detail: "must have a length of at most 2"},
+				{Pointer: "/address", Detail: "is required"},
+			},
+		},
+		{
+			name: "nested and Validator",
+			v: &validEntity{
+				Name: "admin", Age: 20, Role: "user",
+				Address: &validAddress{Zip: "1"},
+				Others:  []validAddress{{City: "lux"}, {}},
+			},
+			wantErrors: []FieldError{
+				{Pointer: "/address/city", Detail: "is required"},
+				{Pointer: "/address/zip", Detail: "must have a length of 4"},
+				{Pointer: "/others/1/city", Detail: "is required"},
+				{Pointer: "/role", Detail: "must be admin"},
+			},
+		},
+		{
+			name:    "unknown rule",
+			v:       &validBadTag{},
+			wantErr: true,
+		},
+		{
+			name: "no validation",
+			v:    &validPlain{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.v)
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				if !errors.Is(err, ErrUnprocessableEntity) {
+					t.Errorf("Validate() error does not wrap ErrUnprocessableEntity")
+				}
+				if !reflect.DeepEqual(ve.Errors, tt.wantErrors) {
+					t.Errorf("Validate() errors = %v, want %v",
+						ve.Errors, tt.wantErrors)
+				}
+				return
+			}
+			if (err != nil) != tt.wantErr || tt.wantErrors != nil {
+				t.Errorf("Validate() error = %v, want errors %v",
+					err, tt.wantErrors)
+			}
+		})
+	}
+}
+
+// validNode is a recursive type, its cycle being evaluated first
+type validNode struct {
+	Child *validNode `json:"child"`
+	Name  string     `json:"name" validate:"required"`
+}
+
+type validTree struct {
+	Root validNode `json:"root"`
+}
+
+func TestHasValidation(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want bool
+	}{
+		{&validEntity{}, true},
+		{validAddress{}, true},
+		{[]*validAddress{}, true},
+		{&validPlain{}, false},
+		{&fieldsEntity{}, false},
+		// the tree first, so that its nodes are evaluated in a cycle
+		{&validTree{}, true},
+		{&validNode{}, true},
+	}
+
+	for _, tt := range tests {
+		if got := HasValidation(tt.v); got != tt.want {
+			t.Errorf("HasValidation(%T) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
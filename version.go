@@ -0,0 +1,34 @@
+package crud
+
+import (
+	"context"
+	"time"
+)
+
+// Versioner is a manager exposing the version of its entities,
+// the REST wrapper uses it as their ETag for optimistic concurrency
+// A version changes every time its entity does
+type Versioner interface {
+	Version(entity interface{}) string
+}
+
+type ifMatchCtxKey struct{}
+
+// WithIfMatch returns a context carrying the version an update expects
+// the entity to have, set by the REST wrapper from If-Match
+func WithIfMatch(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, ifMatchCtxKey{}, version)
+}
+
+// IfMatchFromContext returns the version an update expects the entity to
+// have, a manager can use it to compare and swap in its storage
+func IfMatchFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(ifMatchCtxKey{}).(string)
+	return v, ok
+}
+
+// LastModifier is a manager exposing when its entities last changed,
+// the REST wrapper uses it for Last-Modified and If-Modified-Since
+type LastModifier interface {
+	LastModified(entity interface{}) time.Time
+}